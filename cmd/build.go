@@ -3,11 +3,10 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"strings"
 
 	"github.com/nalanj/confl"
 	"github.com/nalanj/ladle/config"
+	"github.com/nalanj/ladle/core"
 	"github.com/spf13/cobra"
 )
 
@@ -46,14 +45,14 @@ var buildCmd = &cobra.Command{
 				os.Exit(-1)
 			}
 
-			if err := build(conf, args[0], function); err != nil {
+			if err := core.BuildFunction(conf, function); err != nil {
 				fmt.Println(err)
 				os.Exit(-1)
 			}
 		} else {
 			// build all functions
-			for funcName, function := range conf.Functions {
-				if err := build(conf, funcName, function); err != nil {
+			for _, function := range conf.Functions {
+				if err := core.BuildFunction(conf, function); err != nil {
 					fmt.Println(err)
 					os.Exit(-1)
 				}
@@ -62,19 +61,3 @@ var buildCmd = &cobra.Command{
 
 	},
 }
-
-// build runs go build against the given function definition
-func build(conf *config.Config, name string, function *config.Function) error {
-	args := []string{
-		"build",
-		"-o",
-		conf.FunctionExecutable(function),
-		function.Package,
-	}
-	fmt.Printf("Fn %s: %s %s\n", name, "go", strings.Join(args, " "))
-	cmd := exec.Command("go", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
-}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nalanj/confl"
+	"github.com/nalanj/ladle/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var exportFormat string
+var exportTitle string
+
+func init() {
+	exportOpenAPICmd.Flags().StringVarP(&exportFormat, "format", "f", "json", "Output format, json or yaml")
+	exportOpenAPICmd.Flags().StringVar(&exportTitle, "title", "", "API title, defaulting to the config's directory name")
+	exportCmd.AddCommand(exportOpenAPICmd)
+	rootCmd.AddCommand(exportCmd)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the configuration in other formats",
+}
+
+var exportOpenAPICmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "Prints an OpenAPI 3 document of the API's routes",
+	Long: `
+		Export openapi prints an OpenAPI 3 document listing each API route,
+		its methods and path parameters, with an
+		x-amazon-apigateway-integration invoking its function.
+	`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		conf, confErr := config.ParsePath(configPath)
+		if confErr != nil {
+			if parseErr, ok := confErr.(*confl.ParseError); ok {
+				fmt.Println(parseErr.ErrorWithCode())
+			}
+
+			fmt.Println(confErr)
+			os.Exit(-1)
+		}
+
+		title := exportTitle
+		if title == "" {
+			dir, absErr := filepath.Abs(filepath.Dir(configPath))
+			if absErr != nil {
+				fmt.Println(absErr)
+				os.Exit(-1)
+			}
+
+			title = filepath.Base(dir)
+		}
+
+		doc := config.ExportOpenAPI(conf, title, "http://"+httpAddress)
+
+		var data []byte
+		var marshalErr error
+		switch exportFormat {
+		case "json":
+			data, marshalErr = json.MarshalIndent(doc, "", "  ")
+		case "yaml":
+			data, marshalErr = yaml.Marshal(doc)
+		default:
+			fmt.Printf("Unknown format %s\n", exportFormat)
+			os.Exit(-1)
+		}
+
+		if marshalErr != nil {
+			fmt.Println(marshalErr)
+			os.Exit(-1)
+		}
+
+		fmt.Println(strings.TrimSuffix(string(data), "\n"))
+	},
+}
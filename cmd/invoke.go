@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/rpc"
@@ -11,7 +13,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var clientContext string
+var cognitoIdentityID string
+var cognitoIdentityPoolID string
+
 func init() {
+	invokeCmd.Flags().StringVar(&clientContext, "client-context", "", "Client context JSON passed to the function")
+	invokeCmd.Flags().StringVar(&cognitoIdentityID, "cognito-identity-id", "", "Cognito identity id passed to the function")
+	invokeCmd.Flags().StringVar(&cognitoIdentityPoolID, "cognito-identity-pool-id", "", "Cognito identity pool id passed to the function")
 	rootCmd.AddCommand(invokeCmd)
 }
 
@@ -63,9 +72,17 @@ var invokeCmd = &cobra.Command{
 			}
 		}
 
+		if clientContext != "" && !json.Valid([]byte(clientContext)) {
+			fmt.Println(errors.New("Client context must be valid JSON"))
+			os.Exit(1)
+		}
+
 		req := &messages.InvokeRequest{
-			RequestId: uuid.Must(uuid.NewV4()).String(),
-			Payload:   payload,
+			RequestId:             uuid.Must(uuid.NewV4()).String(),
+			Payload:               payload,
+			ClientContext:         []byte(clientContext),
+			CognitoIdentityId:     cognitoIdentityID,
+			CognitoIdentityPoolId: cognitoIdentityPoolID,
 		}
 		resp := &messages.InvokeResponse{}
 
@@ -11,7 +11,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var watch bool
+var eager bool
+
 func init() {
+	serveCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Rebuild functions when their sources change")
+	serveCmd.Flags().BoolVarP(&eager, "eager", "e", false, "Start every function on startup instead of on first invocation")
 	rootCmd.AddCommand(serveCmd)
 }
 
@@ -32,6 +37,8 @@ var serveCmd = &cobra.Command{
 
 		conf.RPCAddress = rpcAddress
 		conf.HTTPAddress = httpAddress
+		conf.Watch = watch
+		conf.EagerStart = eager
 
 		if err := core.StartRuntime(conf); err != nil {
 			fmt.Println(err)
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nalanj/confl"
+	"github.com/nalanj/ladle/config"
+	"github.com/nalanj/ladle/jwt"
+	"github.com/spf13/cobra"
+)
+
+var tokenClaims string
+var tokenIssuer string
+var tokenAudience string
+var tokenSubject string
+var tokenScope string
+var tokenExpires time.Duration
+var tokenKeyPath string
+var tokenJWKS bool
+
+func init() {
+	tokenCmd.Flags().StringVar(&tokenClaims, "claims", "", "Claims JSON merged into the token")
+	tokenCmd.Flags().StringVar(&tokenIssuer, "issuer", "", "Issuer claim, defaulting to the JWTAuthorizer's Issuer")
+	tokenCmd.Flags().StringVar(&tokenAudience, "audience", "", "Audience claim, defaulting to the JWTAuthorizer's first audience")
+	tokenCmd.Flags().StringVar(&tokenSubject, "subject", "ladle", "Subject claim")
+	tokenCmd.Flags().StringVar(&tokenScope, "scope", "", "Space separated scopes")
+	tokenCmd.Flags().DurationVar(&tokenExpires, "expires", time.Hour, "How long until the token expires")
+	tokenCmd.Flags().StringVarP(&tokenKeyPath, "key", "k", "", "PEM private key to sign with, defaulting to one generated in .ladle")
+	tokenCmd.Flags().BoolVar(&tokenJWKS, "jwks", false, "Print the JWKS for the signing key instead of a token")
+	rootCmd.AddCommand(tokenCmd)
+}
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Mints a signed JWT for testing JWT authorizers",
+	Long: `
+		Token signs a JWT with a local private key, for calling routes
+		protected by the JWTAuthorizer. When the JWTAuthorizer has no JWKS
+		or Keys, tokens are verified with the same generated key.
+	`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		conf, confErr := config.ParsePath(configPath)
+		if confErr != nil {
+			if parseErr, ok := confErr.(*confl.ParseError); ok {
+				fmt.Println(parseErr.ErrorWithCode())
+			}
+
+			fmt.Println(confErr)
+			os.Exit(-1)
+		}
+
+		keyPath := tokenKeyPath
+		if keyPath == "" {
+			if err := conf.EnsureRuntimeDir(); err != nil {
+				fmt.Println(err)
+				os.Exit(-1)
+			}
+
+			keyPath = conf.TokenKeyPath()
+		}
+
+		key, keyErr := jwt.LoadOrCreateKey(keyPath)
+		if keyErr != nil {
+			fmt.Println(keyErr)
+			os.Exit(-1)
+		}
+
+		if tokenJWKS {
+			jwk, jwkErr := jwt.NewJWK(key.Public(), "")
+			if jwkErr != nil {
+				fmt.Println(jwkErr)
+				os.Exit(-1)
+			}
+
+			data, _ := json.MarshalIndent(jwt.JWKS{Keys: []jwt.JWK{jwk}}, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+
+		claims, claimsErr := tokenClaimSet(conf, time.Now())
+		if claimsErr != nil {
+			fmt.Println(claimsErr)
+			os.Exit(-1)
+		}
+
+		token, signErr := jwt.Sign(claims, key, "")
+		if signErr != nil {
+			fmt.Println(signErr)
+			os.Exit(-1)
+		}
+
+		fmt.Println(token)
+	},
+}
+
+// tokenClaimSet builds the claims of a token from the flags
+func tokenClaimSet(conf *config.Config, now time.Time) (map[string]interface{}, error) {
+	claims := map[string]interface{}{
+		"sub": tokenSubject,
+		"iat": now.Unix(),
+		"exp": now.Add(tokenExpires).Unix(),
+	}
+
+	if auth := conf.API.JWTAuthorizer; auth != nil {
+		claims["iss"] = auth.Issuer
+		claims["aud"] = auth.Audiences[0]
+	}
+
+	if tokenIssuer != "" {
+		claims["iss"] = tokenIssuer
+	}
+
+	if tokenAudience != "" {
+		claims["aud"] = tokenAudience
+	}
+
+	if tokenScope != "" {
+		claims["scope"] = tokenScope
+	}
+
+	if tokenClaims != "" {
+		extra := make(map[string]interface{})
+		if json.Unmarshal([]byte(tokenClaims), &extra) != nil {
+			return nil, errors.New("Claims must be a valid JSON object")
+		}
+
+		for key, val := range extra {
+			claims[key] = val
+		}
+	}
+
+	return claims, nil
+}
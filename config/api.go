@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/nalanj/confl"
+)
+
+const (
+	// DefaultAPIID is the api id used when the config doesn't set one
+	DefaultAPIID = "ladle00000"
+
+	// DefaultStage is the stage name used when the config doesn't set one
+	DefaultStage = "local"
+
+	// DefaultIntegrationTimeout is how long the gateway waits for a function
+	// to respond when the config doesn't set an IntegrationTimeout
+	DefaultIntegrationTimeout = 29 * time.Second
+)
+
+// API is the configuration of the built-in API Gateway
+type API struct {
+	// ID is the api id functions see in their request context
+	ID string
+
+	// Stage is the stage name functions see in their request context. When
+	// Stages are declared it names the one served at the root, if any.
+	Stage string
+
+	// Stages are the stages served under a path prefix of their name, like
+	// /dev
+	Stages map[string]*Stage
+
+	// MissingAuthenticationToken responds to requests that match a route's
+	// path but none of its methods with a 403 Missing Authentication Token,
+	// as API Gateway does, rather than a 405
+	MissingAuthenticationToken bool
+
+	// BinaryMediaTypes are the content types, like image/png or image/*, of
+	// request bodies that are passed to functions base64 encoded
+	BinaryMediaTypes []string
+
+	// IntegrationTimeout is how long the gateway waits for a function to
+	// respond before responding with a 504
+	IntegrationTimeout time.Duration
+
+	// Authorizers are the Lambda authorizers api events can be protected by
+	Authorizers map[string]*Authorizer
+
+	// JWTAuthorizer validates bearer tokens for api events with an
+	// Authorizer of JWT
+	JWTAuthorizer *JWTAuthorizer
+
+	// CORS is the cross-origin resource sharing configuration of all routes,
+	// which api events can override in their Meta
+	CORS *CORS
+
+	// VerboseErrors includes the cause of gateway errors, like a function's
+	// error type and stack trace, in error responses
+	VerboseErrors bool
+}
+
+// IsBinaryMediaType returns true if the content type matches one of the
+// API's binary media types
+func (a API) IsBinaryMediaType(contentType string) bool {
+	mediaType, _, parseErr := mime.ParseMediaType(contentType)
+	if parseErr != nil {
+		return false
+	}
+
+	for _, binaryType := range a.BinaryMediaTypes {
+		binaryType = strings.ToLower(binaryType)
+		if binaryType == "*/*" || binaryType == mediaType {
+			return true
+		}
+
+		if strings.HasSuffix(binaryType, "/*") &&
+			strings.HasPrefix(mediaType, binaryType[:len(binaryType)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readAPI reads the API section of the config
+func readAPI(apiNode confl.Node) (API, error) {
+	api := API{
+		ID:                 DefaultAPIID,
+		Stage:              DefaultStage,
+		IntegrationTimeout: DefaultIntegrationTimeout,
+	}
+
+	if apiNode.Type() != confl.MapType {
+		return api, errors.New("Invalid API section")
+	}
+
+	stageSet := false
+
+	for _, pair := range confl.KVPairs(apiNode) {
+		switch pair.Key.Value() {
+		case "ID":
+			if !confl.IsText(pair.Value) && pair.Value.Type() != confl.NumberType {
+				return api, errors.New("Invalid API ID")
+			}
+
+			api.ID = pair.Value.Value()
+		case "Stage":
+			if !confl.IsText(pair.Value) {
+				return api, errors.New("Invalid API Stage")
+			}
+
+			api.Stage = pair.Value.Value()
+			stageSet = true
+		case "Stages":
+			stages, stagesErr := readStages(pair.Value)
+			if stagesErr != nil {
+				return api, stagesErr
+			}
+
+			api.Stages = stages
+		case "MissingAuthenticationToken":
+			missing, boolErr := readBool(pair.Value)
+			if boolErr != nil {
+				return api, boolErr
+			}
+
+			api.MissingAuthenticationToken = missing
+		case "BinaryMediaTypes":
+			types, typesErr := readStrings(pair.Value)
+			if typesErr != nil {
+				return api, errors.New("Invalid BinaryMediaTypes")
+			}
+
+			api.BinaryMediaTypes = types
+		case "IntegrationTimeout":
+			timeout, timeoutErr := readSeconds(pair.Value)
+			if timeoutErr != nil || timeout <= 0 {
+				return api, errors.New("Invalid API IntegrationTimeout")
+			}
+
+			api.IntegrationTimeout = timeout
+		case "Authorizers":
+			authorizers, authErr := readAuthorizers(pair.Value)
+			if authErr != nil {
+				return api, authErr
+			}
+
+			api.Authorizers = authorizers
+		case "JWTAuthorizer":
+			jwtAuth, jwtErr := readJWTAuthorizer(pair.Value)
+			if jwtErr != nil {
+				return api, jwtErr
+			}
+
+			api.JWTAuthorizer = jwtAuth
+		case "CORS":
+			cors, corsErr := readCORS(pair.Value)
+			if corsErr != nil {
+				return api, corsErr
+			}
+
+			api.CORS = cors
+		case "VerboseErrors":
+			verbose, boolErr := readBool(pair.Value)
+			if boolErr != nil {
+				return api, boolErr
+			}
+
+			api.VerboseErrors = verbose
+		default:
+			return api, errors.New("Unknown API key")
+		}
+	}
+
+	// With stages declared, the root is only served when Stage names one of
+	// them
+	if len(api.Stages) > 0 {
+		if !stageSet {
+			api.Stage = ""
+		} else if api.Stages[api.Stage] == nil {
+			return api, fmt.Errorf("Unknown API Stage %s", api.Stage)
+		}
+	}
+
+	return api, nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBinaryMediaType(t *testing.T) {
+	t.Parallel()
+
+	api := API{BinaryMediaTypes: []string{"image/*", "application/pdf"}}
+
+	tests := []struct {
+		contentType string
+		binary      bool
+	}{
+		{"image/png", true},
+		{"Image/JPEG", true},
+		{"application/pdf", true},
+		{"application/pdf; charset=binary", true},
+		{"application/json", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.contentType, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.binary, api.IsBinaryMediaType(test.contentType))
+		})
+	}
+
+	assert.True(t, API{BinaryMediaTypes: []string{"*/*"}}.IsBinaryMediaType("text/html"))
+}
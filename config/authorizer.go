@@ -0,0 +1,313 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nalanj/confl"
+)
+
+const (
+	// TokenAuthorizer is the Type of authorizers that are passed a token from
+	// a single header
+	TokenAuthorizer = "TOKEN"
+
+	// RequestAuthorizer is the Type of authorizers that are passed the
+	// request's headers, query string and path parameters
+	RequestAuthorizer = "REQUEST"
+
+	// DefaultAuthorizerTTL is how long authorizer results are cached when the
+	// config doesn't set a TTL
+	DefaultAuthorizerTTL = 300 * time.Second
+
+	// MaxAuthorizerTTL is the longest authorizer results can be cached for
+	MaxAuthorizerTTL = 3600 * time.Second
+)
+
+// DefaultIdentitySource is the identity source of authorizers that don't set
+// one
+var DefaultIdentitySource = []string{"method.request.header.Authorization"}
+
+// Authorizer is a Lambda authorizer for api events
+type Authorizer struct {
+	// Name is the name api events refer to the authorizer by
+	Name string
+
+	// Function is the function invoked to authorize requests, defaulting to
+	// the authorizer's name
+	Function string
+
+	// Type is TokenAuthorizer or RequestAuthorizer
+	Type string
+
+	// IdentitySource is the request values, like
+	// method.request.header.Authorization, that identify the caller. Requests
+	// missing any of them are rejected without invoking the authorizer.
+	IdentitySource []string
+
+	// TTL is how long results are cached for an identity, or 0 for no caching
+	TTL time.Duration
+}
+
+// readAuthorizers reads the authorizers of the API section
+func readAuthorizers(authsNode confl.Node) (map[string]*Authorizer, error) {
+	if authsNode.Type() != confl.MapType {
+		return nil, errors.New("Expected map for Authorizers")
+	}
+
+	authorizers := make(map[string]*Authorizer)
+
+	for _, pair := range confl.KVPairs(authsNode) {
+		auth, authErr := readAuthorizer(pair.Key, pair.Value)
+		if authErr != nil {
+			return nil, authErr
+		}
+
+		authorizers[auth.Name] = auth
+	}
+
+	return authorizers, nil
+}
+
+// readAuthorizer reads a single authorizer
+func readAuthorizer(authKey confl.Node, authNode confl.Node) (*Authorizer, error) {
+	name := authKey.Value()
+
+	if authNode.Type() != confl.MapType {
+		return nil, fmt.Errorf("Invalid authorizer %s", name)
+	}
+
+	auth := &Authorizer{
+		Name:           name,
+		Function:       name,
+		Type:           TokenAuthorizer,
+		IdentitySource: DefaultIdentitySource,
+		TTL:            DefaultAuthorizerTTL,
+	}
+
+	for _, pair := range confl.KVPairs(authNode) {
+		switch pair.Key.Value() {
+		case "Function":
+			if !confl.IsText(pair.Value) {
+				return nil, fmt.Errorf("Invalid function for authorizer %s", name)
+			}
+
+			auth.Function = pair.Value.Value()
+		case "Type":
+			authType := strings.ToUpper(pair.Value.Value())
+			if !confl.IsText(pair.Value) ||
+				(authType != TokenAuthorizer && authType != RequestAuthorizer) {
+				return nil, fmt.Errorf("Invalid type for authorizer %s", name)
+			}
+
+			auth.Type = authType
+		case "IdentitySource":
+			sources, sourcesErr := readStrings(pair.Value)
+			if sourcesErr != nil && confl.IsText(pair.Value) {
+				sources, sourcesErr = []string{pair.Value.Value()}, nil
+			}
+			if sourcesErr != nil || !validIdentitySources(sources) {
+				return nil, fmt.Errorf(
+					"Invalid identity source for authorizer %s",
+					name,
+				)
+			}
+
+			auth.IdentitySource = sources
+		case "TTL":
+			ttl, ttlErr := readSeconds(pair.Value)
+			if ttlErr != nil || ttl < 0 || ttl > MaxAuthorizerTTL {
+				return nil, fmt.Errorf("Invalid TTL for authorizer %s", name)
+			}
+
+			auth.TTL = ttl
+		default:
+			return nil, fmt.Errorf("Unknown key for authorizer %s", name)
+		}
+	}
+
+	if auth.Type == TokenAuthorizer && len(auth.IdentitySource) != 1 {
+		return nil, fmt.Errorf(
+			"Token authorizer %s needs a single identity source",
+			name,
+		)
+	}
+
+	return auth, nil
+}
+
+// identitySourcePrefixes are the prefixes of valid identity sources
+var identitySourcePrefixes = []string{
+	"method.request.header.",
+	"method.request.querystring.",
+}
+
+// validIdentitySources returns true if each identity source is a request
+// value API Gateway can identify callers by
+func validIdentitySources(sources []string) bool {
+	if len(sources) == 0 {
+		return false
+	}
+
+	for _, source := range sources {
+		valid := false
+		for _, prefix := range identitySourcePrefixes {
+			if strings.HasPrefix(source, prefix) && len(source) > len(prefix) {
+				valid = true
+			}
+		}
+
+		if !valid {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateAuthorizers checks that the authorizers api events refer to exist
+// and that their functions exist
+func validateAuthorizers(conf *Config) error {
+	if _, ok := conf.API.Authorizers[JWTAuthorizerName]; ok {
+		return fmt.Errorf("Authorizers can't be named %s", JWTAuthorizerName)
+	}
+
+	for _, auth := range conf.API.Authorizers {
+		if _, ok := conf.Functions[auth.Function]; !ok {
+			return fmt.Errorf(
+				"Unknown function %s for authorizer %s",
+				auth.Function,
+				auth.Name,
+			)
+		}
+	}
+
+	for _, event := range conf.Events {
+		name := event.Meta["Authorizer"]
+		if event.Source != APISource || name == "" {
+			continue
+		}
+
+		if name == JWTAuthorizerName {
+			if conf.API.JWTAuthorizer == nil {
+				return fmt.Errorf("No JWTAuthorizer for event %s", event)
+			}
+
+			continue
+		}
+
+		if _, ok := conf.API.Authorizers[name]; !ok {
+			return fmt.Errorf("Unknown authorizer %s for event %s", name, event)
+		}
+	}
+
+	return nil
+}
+
+// JWTAuthorizerName is the Authorizer api events name to be protected by the
+// API's JWTAuthorizer
+const JWTAuthorizerName = "JWT"
+
+// JWTAuthorizer validates bearer tokens the way HTTP API JWT authorizers do
+type JWTAuthorizer struct {
+	// Issuer is the iss claim tokens must have
+	Issuer string
+
+	// Audiences are the aud or client_id claims tokens may have
+	Audiences []string
+
+	// JWKS is the path, relative to the config, of the JSON Web Key Set that
+	// tokens are verified with
+	JWKS string
+
+	// Keys are JSON Web Keys that tokens are verified with, given inline
+	Keys []map[string]string
+}
+
+// readJWTAuthorizer reads the JWTAuthorizer of the API section
+func readJWTAuthorizer(authNode confl.Node) (*JWTAuthorizer, error) {
+	if authNode.Type() != confl.MapType {
+		return nil, errors.New("Invalid JWTAuthorizer")
+	}
+
+	auth := &JWTAuthorizer{}
+
+	for _, pair := range confl.KVPairs(authNode) {
+		switch pair.Key.Value() {
+		case "Issuer":
+			if !confl.IsText(pair.Value) {
+				return nil, errors.New("Invalid JWTAuthorizer Issuer")
+			}
+
+			auth.Issuer = pair.Value.Value()
+		case "Audiences":
+			audiences, audErr := readStrings(pair.Value)
+			if audErr != nil && confl.IsText(pair.Value) {
+				audiences, audErr = []string{pair.Value.Value()}, nil
+			}
+			if audErr != nil {
+				return nil, errors.New("Invalid JWTAuthorizer Audiences")
+			}
+
+			auth.Audiences = audiences
+		case "JWKS":
+			if !confl.IsText(pair.Value) {
+				return nil, errors.New("Invalid JWTAuthorizer JWKS")
+			}
+
+			auth.JWKS = pair.Value.Value()
+		case "Keys":
+			keys, keysErr := readKeys(pair.Value)
+			if keysErr != nil {
+				return nil, keysErr
+			}
+
+			auth.Keys = keys
+		default:
+			return nil, errors.New("Unknown JWTAuthorizer key")
+		}
+	}
+
+	if auth.Issuer == "" {
+		return nil, errors.New("JWTAuthorizer needs an Issuer")
+	}
+
+	if len(auth.Audiences) == 0 {
+		return nil, errors.New("JWTAuthorizer needs Audiences")
+	}
+
+	if auth.JWKS != "" && auth.Keys != nil {
+		return nil, errors.New("JWTAuthorizer can't have both JWKS and Keys")
+	}
+
+	return auth, nil
+}
+
+// readKeys reads a list of inline JSON Web Keys
+func readKeys(keysNode confl.Node) ([]map[string]string, error) {
+	if keysNode.Type() != confl.ListType {
+		return nil, errors.New("Expected list for JWTAuthorizer Keys")
+	}
+
+	keys := []map[string]string{}
+	for _, keyNode := range keysNode.Children() {
+		if keyNode.Type() != confl.MapType {
+			return nil, errors.New("Invalid JWTAuthorizer key")
+		}
+
+		key := make(map[string]string)
+		for _, pair := range confl.KVPairs(keyNode) {
+			if !confl.IsText(pair.Value) {
+				return nil, errors.New("Invalid JWTAuthorizer key")
+			}
+
+			key[pair.Key.Value()] = pair.Value.Value()
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
@@ -7,10 +7,25 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nalanj/confl"
 )
 
+const (
+	// DefaultRegion is the region used when the config doesn't set one
+	DefaultRegion = "us-east-1"
+
+	// DefaultAccountID is the account id used when the config doesn't set one
+	DefaultAccountID = "123456789012"
+
+	// DefaultKeepWarm is how long idle function instances are kept running
+	// when the config doesn't set a KeepWarm period
+	DefaultKeepWarm = 5 * time.Minute
+)
+
 // Config is a struct representing the configuration of the service
 type Config struct {
 	// Path is the path to the config file
@@ -22,29 +37,56 @@ type Config struct {
 	// HTTPAddress is the address for listening for HTTP
 	HTTPAddress string
 
+	// Watch is true if function sources are watched and rebuilt on change
+	Watch bool
+
+	// EagerStart is true if every function is started when the runtime
+	// starts, rather than on its first invocation
+	EagerStart bool
+
+	// KeepWarm is how long an idle function instance is kept running
+	KeepWarm time.Duration
+
 	// Functions is a map of the named functions for access to their
 	// configurations
 	Functions map[string]*Function
 
 	// Events is a slice of defined events
 	Events []*Event
+
+	// API is the configuration of the built-in API Gateway
+	API API
+
+	// Environment is a map of environment variables shared by all functions
+	Environment map[string]string
+
+	// Region is the AWS region functions appear to run in
+	Region string
+
+	// AccountID is the AWS account id functions appear to run under
+	AccountID string
+
+	// InheritRuntimeEnvironment passes runtime environment variables, like
+	// AWS_REGION, through from ladle's own environment instead of replacing
+	// them with the values Lambda would set
+	InheritRuntimeEnvironment bool
 }
 
 // ParsePath parses the config file at the given path and returns the resulting
 // config
-func ParsePath(path string) (*Config, error) {
-	file, openErr := os.Open(path)
+func ParsePath(filePath string) (*Config, error) {
+	file, openErr := os.Open(filePath)
 	if openErr != nil {
 		return nil, openErr
 	}
 	defer file.Close()
 
-	conf, parseErr := parse(file)
+	conf, parseErr := parse(file, path.Dir(filePath))
 	if parseErr != nil {
 		return nil, parseErr
 	}
 
-	conf.Path = path
+	conf.Path = filePath
 	return conf, nil
 }
 
@@ -74,16 +116,70 @@ func (conf *Config) FunctionExecutable(f *Function) string {
 	return path.Join(conf.RuntimeDir(), f.Name) + ext
 }
 
+// FunctionEnvironment returns the environment for the given function, with
+// the function's own variables merged over the shared Environment
+func (conf *Config) FunctionEnvironment(f *Function) map[string]string {
+	env := make(map[string]string)
+
+	for key, val := range conf.Environment {
+		env[key] = val
+	}
+
+	for key, val := range f.Environment {
+		env[key] = val
+	}
+
+	return env
+}
+
+// FunctionArn returns the synthetic ARN for the given function
+func (conf *Config) FunctionArn(f *Function) string {
+	return fmt.Sprintf(
+		"arn:aws:lambda:%s:%s:function:%s",
+		conf.Region,
+		conf.AccountID,
+		f.Name,
+	)
+}
+
+// TokenKeyPath is the path of the private key ladle token signs tokens with
+func (conf *Config) TokenKeyPath() string {
+	return path.Join(conf.RuntimeDir(), "token_key.pem")
+}
+
+// JWKSPath is the path of the JWTAuthorizer's JSON Web Key Set, relative to
+// the config
+func (conf *Config) JWKSPath() string {
+	if conf.API.JWTAuthorizer == nil || conf.API.JWTAuthorizer.JWKS == "" {
+		return ""
+	}
+
+	if path.IsAbs(conf.API.JWTAuthorizer.JWKS) {
+		return conf.API.JWTAuthorizer.JWKS
+	}
+
+	return path.Join(path.Dir(conf.Path), conf.API.JWTAuthorizer.JWKS)
+}
+
 // PublicDir is the public/ path next to the config, for serving public
 // static files
 func (conf *Config) PublicDir() string {
 	return path.Join(path.Dir(conf.Path), "public")
 }
 
-// parse parses the config file and returns the resulting config
-func parse(reader io.Reader) (*Config, error) {
+// parse parses the config file and returns the resulting config. Files the
+// config refers to, like OpenAPI documents, are relative to dir.
+func parse(reader io.Reader, dir string) (*Config, error) {
 	conf := &Config{
 		Functions: make(map[string]*Function),
+		Region:    DefaultRegion,
+		AccountID: DefaultAccountID,
+		KeepWarm:  DefaultKeepWarm,
+		API: API{
+			ID:                 DefaultAPIID,
+			Stage:              DefaultStage,
+			IntegrationTimeout: DefaultIntegrationTimeout,
+		},
 	}
 
 	doc, parseErr := confl.Parse(reader)
@@ -105,11 +201,68 @@ func parse(reader io.Reader) (*Config, error) {
 				return nil, eventsErr
 			}
 			conf.Events = events
+		case "API":
+			api, apiErr := readAPI(pair.Value)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+
+			conf.API = api
+		case "Environment":
+			env, envErr := readEnvironment(pair.Value)
+			if envErr != nil {
+				return nil, envErr
+			}
+			conf.Environment = env
+		case "Region":
+			if !confl.IsText(pair.Value) {
+				return nil, errors.New("Invalid Region")
+			}
+
+			conf.Region = pair.Value.Value()
+		case "AccountID":
+			if !confl.IsText(pair.Value) && pair.Value.Type() != confl.NumberType {
+				return nil, errors.New("Invalid AccountID")
+			}
+
+			conf.AccountID = pair.Value.Value()
+		case "InheritRuntimeEnvironment":
+			inherit, boolErr := readBool(pair.Value)
+			if boolErr != nil {
+				return nil, boolErr
+			}
+
+			conf.InheritRuntimeEnvironment = inherit
+		case "KeepWarm":
+			keepWarm, keepWarmErr := readSeconds(pair.Value)
+			if keepWarmErr != nil || keepWarm <= 0 {
+				return nil, errors.New("Invalid KeepWarm")
+			}
+
+			conf.KeepWarm = keepWarm
 		default:
 			return nil, fmt.Errorf("Unknown key")
 		}
 	}
 
+	if importErr := importOpenAPI(conf, dir); importErr != nil {
+		return nil, importErr
+	}
+
+	if conflictErr := checkConflicts(conf.Events); conflictErr != nil {
+		return nil, conflictErr
+	}
+
+	if authErr := validateAuthorizers(conf); authErr != nil {
+		return nil, authErr
+	}
+
+	for _, event := range conf.Events {
+		if _, corsErr := mergeCORS(conf.API.CORS, event.Meta); corsErr != nil {
+			return nil, fmt.Errorf("%s for event %s", corsErr, event)
+		}
+	}
+
 	return conf, nil
 }
 
@@ -141,7 +294,12 @@ func readFunction(fnKey confl.Node, fnNode confl.Node) (*Function, error) {
 		return nil, errors.New("Invalid function definition")
 	}
 
-	out := &Function{Name: name}
+	out := &Function{
+		Name:                name,
+		Timeout:             DefaultTimeout,
+		MemorySize:          DefaultMemorySize,
+		ReservedConcurrency: DefaultReservedConcurrency,
+	}
 
 	for _, pair := range confl.KVPairs(fnNode) {
 		switch pair.Key.Value() {
@@ -151,6 +309,50 @@ func readFunction(fnKey confl.Node, fnNode confl.Node) (*Function, error) {
 			}
 
 			out.Package = pair.Value.Value()
+		case "Environment":
+			env, envErr := readEnvironment(pair.Value)
+			if envErr != nil {
+				return nil, envErr
+			}
+
+			out.Environment = env
+		case "Timeout":
+			timeout, timeoutErr := readSeconds(pair.Value)
+			if timeoutErr != nil || timeout <= 0 || timeout > MaxTimeout {
+				return nil, fmt.Errorf("Invalid timeout for function %s", name)
+			}
+
+			out.Timeout = timeout
+		case "MemorySize":
+			if pair.Value.Type() != confl.NumberType {
+				return nil, fmt.Errorf("Invalid memory size for function %s", name)
+			}
+
+			memorySize, convErr := strconv.Atoi(pair.Value.Value())
+			if convErr != nil ||
+				memorySize < MinMemorySize ||
+				memorySize > MaxMemorySize {
+				return nil, fmt.Errorf("Invalid memory size for function %s", name)
+			}
+
+			out.MemorySize = memorySize
+		case "ReservedConcurrency":
+			if pair.Value.Type() != confl.NumberType {
+				return nil, fmt.Errorf(
+					"Invalid reserved concurrency for function %s",
+					name,
+				)
+			}
+
+			concurrency, convErr := strconv.Atoi(pair.Value.Value())
+			if convErr != nil || concurrency < 1 {
+				return nil, fmt.Errorf(
+					"Invalid reserved concurrency for function %s",
+					name,
+				)
+			}
+
+			out.ReservedConcurrency = concurrency
 		default:
 			return nil, errors.New("Invalid key")
 		}
@@ -159,6 +361,82 @@ func readFunction(fnKey confl.Node, fnNode confl.Node) (*Function, error) {
 	return out, nil
 }
 
+// readEnvironment reads a map of environment variables
+func readEnvironment(envNode confl.Node) (map[string]string, error) {
+	if envNode.Type() != confl.MapType {
+		return nil, errors.New("Expected map for Environment")
+	}
+
+	env := make(map[string]string)
+
+	for _, pair := range confl.KVPairs(envNode) {
+		key := pair.Key.Value()
+		if key == "" || strings.ContainsAny(key, "= ") {
+			return nil, fmt.Errorf("Invalid environment variable name %q", key)
+		}
+
+		if IsRuntimeEnvironmentVariable(key) {
+			return nil, fmt.Errorf("Reserved environment variable %s", key)
+		}
+
+		if !confl.IsText(pair.Value) && pair.Value.Type() != confl.NumberType {
+			return nil, fmt.Errorf("Invalid value for environment variable %s", key)
+		}
+
+		env[key] = pair.Value.Value()
+	}
+
+	return env, nil
+}
+
+// readSeconds reads a whole number of seconds as a duration
+func readSeconds(node confl.Node) (time.Duration, error) {
+	if node.Type() != confl.NumberType {
+		return 0, errors.New("Expected a number of seconds")
+	}
+
+	seconds, convErr := strconv.Atoi(node.Value())
+	if convErr != nil {
+		return 0, convErr
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// readBool reads a boolean word like true or false
+func readBool(node confl.Node) (bool, error) {
+	if node.Type() != confl.WordType {
+		return false, errors.New("Expected a boolean")
+	}
+
+	switch strings.ToLower(node.Value()) {
+	case "true", "yes":
+		return true, nil
+	case "false", "no":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("Invalid boolean %s", node.Value())
+}
+
+// readStrings reads a list of text values
+func readStrings(node confl.Node) ([]string, error) {
+	if node.Type() != confl.ListType {
+		return nil, errors.New("Expected a list")
+	}
+
+	values := []string{}
+	for _, child := range node.Children() {
+		if !confl.IsText(child) {
+			return nil, errors.New("Expected a list of text values")
+		}
+
+		values = append(values, child.Value())
+	}
+
+	return values, nil
+}
+
 // readEvents reads confl nodes and converts them to events
 func readEvents(eventsNode confl.Node) ([]*Event, error) {
 	if eventsNode.Type() != confl.ListType {
@@ -179,6 +457,24 @@ func readEvents(eventsNode confl.Node) ([]*Event, error) {
 	return events, nil
 }
 
+// checkConflicts rejects ambiguous api events, including those imported from
+// OpenAPI documents
+func checkConflicts(events []*Event) error {
+	for i, event := range events {
+		for _, other := range events[:i] {
+			if event.conflicts(other) {
+				return fmt.Errorf(
+					"Event %s conflicts with event %s",
+					event,
+					other,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
 // readEvent reads a single event from confl
 func readEvent(eventNode confl.Node) (*Event, error) {
 	if eventNode.Type() != confl.MapType {
@@ -214,6 +510,23 @@ func readEvent(eventNode confl.Node) (*Event, error) {
 		}
 	}
 
+	if !validRoute(event.Meta["Route"]) {
+		return nil, fmt.Errorf("Invalid event route %s", event.Meta["Route"])
+	}
+
+	if version := event.PayloadVersion(); version != PayloadVersion1 &&
+		version != PayloadVersion2 {
+		return nil, fmt.Errorf("Invalid event payload version %s", version)
+	}
+
+	if method, ok := event.Meta["Method"]; ok {
+		for _, m := range strings.Split(method, ",") {
+			if !validMethod(m) {
+				return nil, fmt.Errorf("Invalid event method %s", m)
+			}
+		}
+	}
+
 	return event, nil
 }
 
@@ -225,14 +538,17 @@ func readEventMeta(metaNode confl.Node) (map[string]string, error) {
 
 	meta := make(map[string]string)
 
-	var key confl.Node
-	for _, node := range metaNode.Children() {
-		if key == nil {
-			key = node
-		} else {
-			meta[key.Value()] = node.Value()
-			key = nil
+	for _, pair := range confl.KVPairs(metaNode) {
+		if pair.Value.Type() != confl.ListType {
+			meta[pair.Key.Value()] = pair.Value.Value()
+			continue
+		}
+
+		values, valuesErr := readStrings(pair.Value)
+		if valuesErr != nil {
+			return nil, fmt.Errorf("Invalid meta value for %s", pair.Key.Value())
 		}
+		meta[pair.Key.Value()] = strings.Join(values, ",")
 	}
 
 	return meta, nil
@@ -3,6 +3,7 @@ package config
 import (
 	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -31,12 +32,79 @@ func TestParsePath(t *testing.T) {
 			true,
 		},
 		{"unknown function key", "unknown_function_key.confl", nil, true},
+		{"invalid environment", "invalid_environment.confl", nil, true},
+		{
+			"invalid function environment",
+			"invalid_function_environment.confl",
+			nil,
+			true,
+		},
+		{"invalid function timeout", "invalid_function_timeout.confl", nil, true},
+		{
+			"reserved environment variable",
+			"reserved_environment_variable.confl",
+			nil,
+			true,
+		},
+		{
+			"invalid function memory size",
+			"invalid_function_memory_size.confl",
+			nil,
+			true,
+		},
+		{
+			"invalid function reserved concurrency",
+			"invalid_function_reserved_concurrency.confl",
+			nil,
+			true,
+		},
+		{"invalid region", "invalid_region.confl", nil, true},
+		{"invalid inherit", "invalid_inherit.confl", nil, true},
+		{"invalid keep warm", "invalid_keep_warm.confl", nil, true},
 		{"invalid events", "invalid_events.confl", nil, true},
 		{"invalid event type", "invalid_event_type.confl", nil, true},
 		{"invalid event source", "invalid_event_source.confl", nil, true},
 		{"invalid event target", "invalid_event_target.confl", nil, true},
 		{"invalid event meta", "invalid_event_meta.confl", nil, true},
 		{"invalid event key", "invalid_event_key.confl", nil, true},
+		{"invalid event method", "invalid_event_method.confl", nil, true},
+		{"invalid api", "invalid_api.confl", nil, true},
+		{"invalid event route", "invalid_event_route.confl", nil, true},
+		{"conflicting events", "conflicting_events.confl", nil, true},
+		{"invalid authorizer", "invalid_authorizer.confl", nil, true},
+		{"unknown authorizer", "unknown_authorizer.confl", nil, true},
+		{"invalid jwt authorizer", "invalid_jwt_authorizer.confl", nil, true},
+		{"missing jwt authorizer", "missing_jwt_authorizer.confl", nil, true},
+		{"invalid cors", "invalid_cors.confl", nil, true},
+		{"invalid event cors", "invalid_event_cors.confl", nil, true},
+		{"invalid stage", "invalid_stage.confl", nil, true},
+		{"unknown stage", "unknown_stage.confl", nil, true},
+		{"missing openapi", "missing_openapi.confl", nil, true},
+		{"unsupported openapi", "unsupported_openapi.confl", nil, true},
+		{
+			"unsupported openapi integration",
+			"unsupported_openapi_integration.confl",
+			nil,
+			true,
+		},
+		{
+			"unsupported openapi scheme",
+			"unsupported_openapi_scheme.confl",
+			nil,
+			true,
+		},
+		{
+			"unknown authorizer function",
+			"unknown_authorizer_function.confl",
+			nil,
+			true,
+		},
+		{
+			"invalid event payload version",
+			"invalid_event_payload_version.confl",
+			nil,
+			true,
+		},
 		{
 			"valid config",
 			"valid.confl",
@@ -46,15 +114,93 @@ func TestParsePath(t *testing.T) {
 					"Testing": &Function{
 						Name:    "Testing",
 						Package: "function",
+						Environment: map[string]string{
+							"TABLE_NAME": "testing",
+							"RETRIES":    "3",
+						},
+						Timeout:             30 * time.Second,
+						MemorySize:          512,
+						ReservedConcurrency: 2,
 					},
 				},
 				Events: []*Event{
 					&Event{
 						Source: APISource,
 						Target: "Testing",
-						Meta:   map[string]string{"Route": "/Testing"},
+						Meta: map[string]string{
+							"Route":      "/Testing",
+							"Method":     "GET,post",
+							"Authorizer": "Token",
+						},
+					},
+					&Event{
+						Source: APISource,
+						Target: "Testing",
+						Meta: map[string]string{
+							"Route":               "/Private",
+							"Authorizer":          "JWT",
+							"AuthorizationScopes": "read",
+						},
+					},
+				},
+				API: API{
+					ID:                         "abcdef1234",
+					Stage:                      "dev",
+					MissingAuthenticationToken: true,
+					BinaryMediaTypes:           []string{"image/*", "application/pdf"},
+					IntegrationTimeout:         10 * time.Second,
+					VerboseErrors:              true,
+					Stages: map[string]*Stage{
+						"dev": &Stage{
+							Name:      "dev",
+							Variables: map[string]string{"TABLE_NAME": "dev"},
+						},
+						"v1": &Stage{
+							Name:      "v1",
+							Variables: map[string]string{"TABLE_NAME": "v1", "RETRIES": "3"},
+						},
+					},
+					Authorizers: map[string]*Authorizer{
+						"Token": &Authorizer{
+							Name:           "Token",
+							Function:       "Testing",
+							Type:           TokenAuthorizer,
+							IdentitySource: DefaultIdentitySource,
+							TTL:            60 * time.Second,
+						},
+						"Request": &Authorizer{
+							Name:     "Request",
+							Function: "Testing",
+							Type:     RequestAuthorizer,
+							IdentitySource: []string{
+								"method.request.header.X-Api-Key",
+								"method.request.querystring.tenant",
+							},
+							TTL: 0,
+						},
 					},
+					JWTAuthorizer: &JWTAuthorizer{
+						Issuer:    "https://issuer.example.com",
+						Audiences: []string{"web", "mobile"},
+						JWKS:      "jwks.json",
+					},
+					CORS: &CORS{
+						AllowOrigins:     []string{"https://example.com"},
+						AllowMethods:     []string{"GET", "POST"},
+						AllowHeaders:     []string{"*"},
+						ExposeHeaders:    []string{"X-Request-Id"},
+						AllowCredentials: true,
+						MaxAge:           600 * time.Second,
+					},
+				},
+				Environment: map[string]string{
+					"STAGE":      "local",
+					"TABLE_NAME": "shared",
 				},
+				Region:                    "eu-west-1",
+				AccountID:                 "000000000000",
+				InheritRuntimeEnvironment: true,
+				KeepWarm:                  60 * time.Second,
 			},
 			false,
 		},
@@ -72,3 +218,41 @@ func TestParsePath(t *testing.T) {
 		})
 	}
 }
+
+func TestJWKSPath(t *testing.T) {
+	t.Parallel()
+
+	conf := &Config{
+		Path: "service/ladle.confl",
+		API:  API{JWTAuthorizer: &JWTAuthorizer{JWKS: "keys/jwks.json"}},
+	}
+	assert.Equal(t, "service/keys/jwks.json", conf.JWKSPath())
+
+	conf.API.JWTAuthorizer.JWKS = "/etc/jwks.json"
+	assert.Equal(t, "/etc/jwks.json", conf.JWKSPath())
+
+	assert.Equal(t, "", (&Config{Path: "ladle.confl"}).JWKSPath())
+}
+
+func TestFunctionEnvironment(t *testing.T) {
+	t.Parallel()
+
+	conf := &Config{
+		Environment: map[string]string{"STAGE": "local", "TABLE": "shared"},
+	}
+	f := &Function{
+		Name:        "Testing",
+		Environment: map[string]string{"TABLE": "testing"},
+	}
+
+	assert.Equal(
+		t,
+		map[string]string{"STAGE": "local", "TABLE": "testing"},
+		conf.FunctionEnvironment(f),
+	)
+	assert.Equal(
+		t,
+		map[string]string{"STAGE": "local", "TABLE": "shared"},
+		conf.FunctionEnvironment(&Function{Name: "Other"}),
+	)
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nalanj/confl"
+)
+
+// CORS is the cross-origin resource sharing configuration of the API or an
+// api event
+type CORS struct {
+	// AllowOrigins are the origins allowed to call the API, or * for any
+	AllowOrigins []string
+
+	// AllowMethods are the methods preflight requests are allowed, or * for
+	// any
+	AllowMethods []string
+
+	// AllowHeaders are the request headers preflight requests are allowed, or
+	// * for any
+	AllowHeaders []string
+
+	// ExposeHeaders are the response headers exposed to callers
+	ExposeHeaders []string
+
+	// AllowCredentials allows requests with credentials, like cookies
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache preflight responses
+	MaxAge time.Duration
+}
+
+// AllowsOrigin returns true if requests from the origin are allowed
+func (c *CORS) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CORSFor returns the CORS configuration for an api event, which is the
+// API's merged with any CORS keys in the event's Meta. A nil event gets the
+// API's. Nil is returned when CORS isn't configured or the event disables it
+// with CORS=false.
+func (conf *Config) CORSFor(event *Event) *CORS {
+	var meta map[string]string
+	if event != nil {
+		meta = event.Meta
+	}
+
+	cors, _ := mergeCORS(conf.API.CORS, meta)
+	return cors
+}
+
+// readCORS reads the CORS section of the API
+func readCORS(corsNode confl.Node) (*CORS, error) {
+	if corsNode.Type() != confl.MapType {
+		return nil, errors.New("Invalid CORS section")
+	}
+
+	cors := &CORS{}
+
+	for _, pair := range confl.KVPairs(corsNode) {
+		key := pair.Key.Value()
+
+		switch key {
+		case "AllowOrigins", "AllowMethods", "AllowHeaders", "ExposeHeaders":
+			values, valuesErr := readStrings(pair.Value)
+			if valuesErr != nil && confl.IsText(pair.Value) {
+				values, valuesErr = []string{pair.Value.Value()}, nil
+			}
+			if valuesErr != nil {
+				return nil, fmt.Errorf("Invalid CORS %s", key)
+			}
+
+			setCORSList(cors, key, values)
+		case "AllowCredentials":
+			allow, boolErr := readBool(pair.Value)
+			if boolErr != nil {
+				return nil, errors.New("Invalid CORS AllowCredentials")
+			}
+
+			cors.AllowCredentials = allow
+		case "MaxAge":
+			maxAge, maxAgeErr := readSeconds(pair.Value)
+			if maxAgeErr != nil || maxAge < 0 {
+				return nil, errors.New("Invalid CORS MaxAge")
+			}
+
+			cors.MaxAge = maxAge
+		default:
+			return nil, fmt.Errorf("Unknown CORS key %s", key)
+		}
+	}
+
+	if validErr := validateCORS(cors); validErr != nil {
+		return nil, validErr
+	}
+
+	return cors, nil
+}
+
+// mergeCORS merges the CORS keys of an api event's Meta, like
+// CORSAllowOrigins, over the API's CORS configuration
+func mergeCORS(base *CORS, meta map[string]string) (*CORS, error) {
+	if meta["CORS"] != "" {
+		enabled, parseErr := strconv.ParseBool(meta["CORS"])
+		if parseErr != nil {
+			return nil, errors.New("Invalid event CORS")
+		}
+
+		if !enabled {
+			return nil, nil
+		}
+	}
+
+	cors := &CORS{}
+	if base != nil {
+		*cors = *base
+	}
+
+	overridden := false
+	for key, val := range meta {
+		if !strings.HasPrefix(key, "CORS") || key == "CORS" {
+			continue
+		}
+		overridden = true
+
+		switch name := strings.TrimPrefix(key, "CORS"); name {
+		case "AllowOrigins", "AllowMethods", "AllowHeaders", "ExposeHeaders":
+			setCORSList(cors, name, splitList(val))
+		case "AllowCredentials":
+			allow, parseErr := strconv.ParseBool(val)
+			if parseErr != nil {
+				return nil, errors.New("Invalid event CORSAllowCredentials")
+			}
+
+			cors.AllowCredentials = allow
+		case "MaxAge":
+			seconds, convErr := strconv.Atoi(val)
+			if convErr != nil || seconds < 0 {
+				return nil, errors.New("Invalid event CORSMaxAge")
+			}
+
+			cors.MaxAge = time.Duration(seconds) * time.Second
+		default:
+			return nil, fmt.Errorf("Unknown event CORS key %s", key)
+		}
+	}
+
+	if base == nil && !overridden {
+		return nil, nil
+	}
+
+	if validErr := validateCORS(cors); validErr != nil {
+		return nil, validErr
+	}
+
+	return cors, nil
+}
+
+// validateCORS rejects configurations browsers would refuse, like allowing
+// credentials from any origin
+func validateCORS(cors *CORS) error {
+	if !cors.AllowCredentials {
+		return nil
+	}
+
+	for _, origin := range cors.AllowOrigins {
+		if origin == "*" {
+			return errors.New("CORS can't allow credentials from any origin")
+		}
+	}
+
+	return nil
+}
+
+// setCORSList sets one of the list settings of a CORS configuration
+func setCORSList(cors *CORS, name string, values []string) {
+	switch name {
+	case "AllowOrigins":
+		cors.AllowOrigins = values
+	case "AllowMethods":
+		cors.AllowMethods = values
+	case "AllowHeaders":
+		cors.AllowHeaders = values
+	case "ExposeHeaders":
+		cors.ExposeHeaders = values
+	}
+}
+
+// splitList splits a comma separated Meta value
+func splitList(val string) []string {
+	if val == "" {
+		return nil
+	}
+
+	values := []string{}
+	for _, part := range strings.Split(val, ",") {
+		values = append(values, strings.TrimSpace(part))
+	}
+
+	return values
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSFor(t *testing.T) {
+	t.Parallel()
+
+	apiCORS := &CORS{
+		AllowOrigins: []string{"https://example.com"},
+		AllowMethods: []string{"GET"},
+		MaxAge:       time.Minute,
+	}
+
+	tests := []struct {
+		name string
+		api  *CORS
+		meta map[string]string
+		want *CORS
+	}{
+		{"nil without cors", nil, map[string]string{}, nil},
+		{"the api's without overrides", apiCORS, map[string]string{}, apiCORS},
+		{"nil when disabled", apiCORS, map[string]string{"CORS": "false"}, nil},
+		{
+			"merged with overrides",
+			apiCORS,
+			map[string]string{
+				"CORSAllowOrigins":     "https://a.example.com,https://b.example.com",
+				"CORSAllowCredentials": "true",
+				"CORSMaxAge":           "10",
+			},
+			&CORS{
+				AllowOrigins: []string{
+					"https://a.example.com",
+					"https://b.example.com",
+				},
+				AllowMethods:     []string{"GET"},
+				AllowCredentials: true,
+				MaxAge:           10 * time.Second,
+			},
+		},
+		{
+			"from overrides without api cors",
+			nil,
+			map[string]string{"CORSAllowOrigins": "*"},
+			&CORS{AllowOrigins: []string{"*"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := &Config{API: API{CORS: test.api}}
+			event := &Event{Source: APISource, Meta: test.meta}
+			assert.Equal(t, test.want, conf.CORSFor(event))
+		})
+	}
+}
+
+func TestAllowsOrigin(t *testing.T) {
+	t.Parallel()
+
+	cors := &CORS{AllowOrigins: []string{"https://example.com"}}
+	assert.True(t, cors.AllowsOrigin("https://example.com"))
+	assert.True(t, cors.AllowsOrigin("HTTPS://EXAMPLE.COM"))
+	assert.False(t, cors.AllowsOrigin("https://evil.example.com"))
+
+	cors = &CORS{AllowOrigins: []string{"*"}}
+	assert.True(t, cors.AllowsOrigin("https://evil.example.com"))
+}
@@ -0,0 +1,31 @@
+package config
+
+// RuntimeEnvironmentVariables are the standard variables the Lambda runtime
+// sets for every function. Ladle sets them itself, so they can't be
+// configured in an Environment section.
+var RuntimeEnvironmentVariables = []string{
+	"_HANDLER",
+	"AWS_DEFAULT_REGION",
+	"AWS_EXECUTION_ENV",
+	"AWS_LAMBDA_FUNCTION_MEMORY_SIZE",
+	"AWS_LAMBDA_FUNCTION_NAME",
+	"AWS_LAMBDA_FUNCTION_VERSION",
+	"AWS_LAMBDA_LOG_GROUP_NAME",
+	"AWS_LAMBDA_LOG_STREAM_NAME",
+	"AWS_REGION",
+	"LAMBDA_RUNTIME_DIR",
+	"LAMBDA_TASK_ROOT",
+	"TZ",
+}
+
+// IsRuntimeEnvironmentVariable returns true if the given name is one of the
+// standard runtime variables
+func IsRuntimeEnvironmentVariable(name string) bool {
+	for _, runtimeName := range RuntimeEnvironmentVariables {
+		if name == runtimeName {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,10 +1,37 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+)
+
 const (
 	// APISource is the source name of api events
 	APISource = "API"
+
+	// AnyMethod is the Method of api events that match every http method
+	AnyMethod = "ANY"
+
+	// PayloadVersion1 is the PayloadVersion of api events that invoke their
+	// function like a REST API proxy integration
+	PayloadVersion1 = "1.0"
+
+	// PayloadVersion2 is the PayloadVersion of api events that invoke their
+	// function like an HTTP API integration
+	PayloadVersion2 = "2.0"
 )
 
+// HTTPMethods are the http methods api events can be matched against
+var HTTPMethods = []string{
+	"DELETE",
+	"GET",
+	"HEAD",
+	"OPTIONS",
+	"PATCH",
+	"POST",
+	"PUT",
+}
+
 // Event represents an event within the system
 type Event struct {
 	// Source is the source of an event
@@ -13,6 +40,146 @@ type Event struct {
 	// Target is the function to be called on the event
 	Target string
 
-	// Meta is a map of config options that can be applied to the event source
+	// Meta is a map of config options that can be applied to the event source.
+	// List values are joined with commas.
 	Meta map[string]string
 }
+
+// Methods returns the http methods an api event matches, or nil if it
+// matches any method
+func (e *Event) Methods() []string {
+	if e.Meta["Method"] == "" {
+		return nil
+	}
+
+	methods := []string{}
+	for _, method := range strings.Split(e.Meta["Method"], ",") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == AnyMethod {
+			return nil
+		}
+
+		methods = append(methods, method)
+	}
+
+	return methods
+}
+
+// MatchesMethod returns true if an api event matches the given http method
+func (e *Event) MatchesMethod(method string) bool {
+	methods := e.Methods()
+	if methods == nil {
+		return true
+	}
+
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PayloadVersion returns the payload format version an api event invokes its
+// function with
+func (e *Event) PayloadVersion() string {
+	if e.Meta["PayloadVersion"] == "" {
+		return PayloadVersion1
+	}
+
+	return e.Meta["PayloadVersion"]
+}
+
+// validMethod returns true if the method can be used in an event's Method
+func validMethod(method string) bool {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == AnyMethod {
+		return true
+	}
+
+	for _, m := range HTTPMethods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validRoute returns true if the route's greedy {name+} part, if it has one,
+// is its last part
+func validRoute(route string) bool {
+	parts := strings.Split(route, "/")
+	for i, part := range parts {
+		if !strings.HasSuffix(part, "+}") {
+			continue
+		}
+
+		if i != len(parts)-1 || !strings.HasPrefix(part, "{") || len(part) < 4 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String describes the event for use in messages
+func (e *Event) String() string {
+	if e.Source != APISource {
+		return fmt.Sprintf("%s -> %s", e.Source, e.Target)
+	}
+
+	method := AnyMethod
+	if methods := e.Methods(); methods != nil {
+		method = strings.Join(methods, ",")
+	}
+
+	return fmt.Sprintf("%s %s %s -> %s", e.Source, method, e.Meta["Route"], e.Target)
+}
+
+// routeTemplate returns an api event's route with the names of its path
+// parameters removed, so that routes that match the same requests are equal
+func (e *Event) routeTemplate() string {
+	parts := strings.Split(e.Meta["Route"], "/")
+	for i, part := range parts {
+		if strings.HasSuffix(part, "+}") {
+			parts[i] = "{+}"
+		} else if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parts[i] = "{}"
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// conflicts returns true if two api events have equivalent routes and share
+// a method, making it ambiguous which should handle a request
+func (e *Event) conflicts(other *Event) bool {
+	if e.Source != APISource || other.Source != APISource {
+		return false
+	}
+
+	if e.routeTemplate() != other.routeTemplate() {
+		return false
+	}
+
+	methods := e.Methods()
+	if methods == nil {
+		methods = []string{AnyMethod}
+	}
+	otherMethods := other.Methods()
+	if otherMethods == nil {
+		otherMethods = []string{AnyMethod}
+	}
+
+	for _, method := range methods {
+		for _, otherMethod := range otherMethods {
+			if method == otherMethod {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventMethods(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		method  string
+		methods []string
+		matches map[string]bool
+	}{
+		{
+			"no method",
+			"",
+			nil,
+			map[string]bool{"GET": true, "DELETE": true},
+		},
+		{
+			"any method",
+			"ANY",
+			nil,
+			map[string]bool{"GET": true, "DELETE": true},
+		},
+		{
+			"single method",
+			"get",
+			[]string{"GET"},
+			map[string]bool{"GET": true, "DELETE": false},
+		},
+		{
+			"method list",
+			"GET,POST",
+			[]string{"GET", "POST"},
+			map[string]bool{"GET": true, "POST": true, "DELETE": false},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			event := &Event{
+				Source: APISource,
+				Meta:   map[string]string{"Method": test.method},
+			}
+
+			assert.Equal(t, test.methods, event.Methods())
+			for method, match := range test.matches {
+				assert.Equal(t, match, event.MatchesMethod(method), method)
+			}
+		})
+	}
+}
+
+func TestEventConflicts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		route     string
+		method    string
+		other     string
+		otherMeth string
+		conflicts bool
+	}{
+		{"same route and method", "/users", "GET", "/users", "GET", true},
+		{"different methods", "/users", "GET", "/users", "POST", false},
+		{"any and a method", "/users", "ANY", "/users", "GET", false},
+		{"both any", "/users", "", "/users", "ANY", true},
+		{"renamed param", "/users/{id}", "GET", "/users/{userID}", "GET", true},
+		{"param and literal", "/users/{id}", "GET", "/users/me", "GET", false},
+		{"param and greedy", "/{id}", "GET", "/{proxy+}", "GET", false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			event := &Event{
+				Source: APISource,
+				Meta:   map[string]string{"Route": test.route, "Method": test.method},
+			}
+			other := &Event{
+				Source: APISource,
+				Meta: map[string]string{
+					"Route":  test.other,
+					"Method": test.otherMeth,
+				},
+			}
+
+			assert.Equal(t, test.conflicts, event.conflicts(other))
+		})
+	}
+}
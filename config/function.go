@@ -1,5 +1,30 @@
 package config
 
+import "time"
+
+const (
+	// DefaultTimeout is the invocation timeout used when a function doesn't
+	// configure one, matching the Lambda default
+	DefaultTimeout = 3 * time.Second
+
+	// MaxTimeout is the longest timeout a function may configure
+	MaxTimeout = 900 * time.Second
+
+	// DefaultMemorySize is the memory size in MB used when a function doesn't
+	// configure one
+	DefaultMemorySize = 128
+
+	// MinMemorySize is the smallest memory size in MB a function may configure
+	MinMemorySize = 128
+
+	// MaxMemorySize is the largest memory size in MB a function may configure
+	MaxMemorySize = 10240
+
+	// DefaultReservedConcurrency is the number of concurrent instances a
+	// function may run when it doesn't configure a reserved concurrency
+	DefaultReservedConcurrency = 10
+)
+
 // Function represents a specific function being run
 type Function struct {
 	// Name is the name of the function
@@ -7,4 +32,17 @@ type Function struct {
 
 	// Package is the go package to be built for the function
 	Package string
+
+	// Environment is a map of environment variables set for the function
+	Environment map[string]string
+
+	// Timeout is the maximum duration of an invocation of the function
+	Timeout time.Duration
+
+	// MemorySize is the amount of memory in MB reported to the function
+	MemorySize int
+
+	// ReservedConcurrency is the maximum number of instances of the function
+	// that may run at once
+	ReservedConcurrency int
 }
@@ -0,0 +1,558 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// openAPIExtensionPrefix is the prefix of API Gateway's OpenAPI extensions
+const openAPIExtensionPrefix = "x-amazon-apigateway-"
+
+// openAPIAnyMethod is the path item extension API Gateway uses for ANY
+// methods
+const openAPIAnyMethod = "x-amazon-apigateway-any-method"
+
+// openAPIDocument is the part of an OpenAPI 3 or Swagger 2 document ladle
+// reads routes from
+type openAPIDocument struct {
+	OpenAPI             string                            `yaml:"openapi"`
+	Swagger             string                            `yaml:"swagger"`
+	BasePath            string                            `yaml:"basePath"`
+	Paths               map[string]map[string]interface{} `yaml:"paths"`
+	Security            []map[string][]string             `yaml:"security"`
+	SecurityDefinitions map[string]*openAPISecurityScheme `yaml:"securityDefinitions"`
+	Components          struct {
+		SecuritySchemes map[string]*openAPISecurityScheme `yaml:"securitySchemes"`
+	} `yaml:"components"`
+	BinaryMediaTypes []string               `yaml:"x-amazon-apigateway-binary-media-types"`
+	Extra            map[string]interface{} `yaml:",inline"`
+}
+
+// openAPIOperation is an operation of a path in an OpenAPI document
+type openAPIOperation struct {
+	Security    *[]map[string][]string `yaml:"security"`
+	Integration *openAPIIntegration    `yaml:"x-amazon-apigateway-integration"`
+	Extra       map[string]interface{} `yaml:",inline"`
+}
+
+// openAPIIntegration is an x-amazon-apigateway-integration extension
+type openAPIIntegration struct {
+	Type                 string      `yaml:"type"`
+	URI                  interface{} `yaml:"uri"`
+	PayloadFormatVersion string      `yaml:"payloadFormatVersion"`
+}
+
+// openAPISecurityScheme is a security scheme of an OpenAPI document. Its
+// AuthType is only informational, as the authorizer's type decides how
+// requests are authorized.
+type openAPISecurityScheme struct {
+	AuthType   string                 `yaml:"x-amazon-apigateway-authtype"`
+	Authorizer *openAPIAuthorizer     `yaml:"x-amazon-apigateway-authorizer"`
+	Extra      map[string]interface{} `yaml:",inline"`
+}
+
+// openAPIAuthorizer is an x-amazon-apigateway-authorizer extension
+type openAPIAuthorizer struct {
+	Type             string      `yaml:"type"`
+	AuthorizerURI    interface{} `yaml:"authorizerUri"`
+	IdentitySource   interface{} `yaml:"identitySource"`
+	ResultTTL        *int        `yaml:"authorizerResultTtlInSeconds"`
+	JWTConfiguration struct {
+		Issuer   string   `yaml:"issuer"`
+		Audience []string `yaml:"audience"`
+	} `yaml:"jwtConfiguration"`
+}
+
+// openAPIMethods maps the operations of an OpenAPI path item to methods
+var openAPIMethods = map[string]string{
+	"get":            "GET",
+	"put":            "PUT",
+	"post":           "POST",
+	"delete":         "DELETE",
+	"options":        "OPTIONS",
+	"head":           "HEAD",
+	"patch":          "PATCH",
+	openAPIAnyMethod: AnyMethod,
+}
+
+// openAPIFunctionPatterns extract the function name from the Lambda ARN of
+// an integration URI, either a literal ARN or a CloudFormation reference
+var openAPIFunctionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^arn:aws[a-z-]*:lambda:[^:]*:[^:]*:function:([^:/]+)(:[^/]+)?$`),
+	regexp.MustCompile(`^\$\{([A-Za-z0-9]+)\.Arn\}$`),
+}
+
+// openAPIURIPattern matches the Lambda invocation URIs of integrations
+var openAPIURIPattern = regexp.MustCompile(`/functions/(.+)/invocations$`)
+
+// openAPIPath returns the path of an api event's OpenAPI document, relative
+// to the config in the given dir
+func (e *Event) openAPIPath(dir string) string {
+	openAPI := e.Meta["OpenAPI"]
+	if openAPI == "" || path.IsAbs(openAPI) {
+		return openAPI
+	}
+
+	return path.Join(dir, openAPI)
+}
+
+// importOpenAPI replaces the api events that point at an OpenAPI document
+// with an event for each of its operations, and adds the document's binary
+// media types and authorizers to the API
+func importOpenAPI(conf *Config, dir string) error {
+	events := []*Event{}
+
+	for _, event := range conf.Events {
+		if event.Source != APISource || event.Meta["OpenAPI"] == "" {
+			events = append(events, event)
+			continue
+		}
+
+		file := event.openAPIPath(dir)
+		imported, importErr := readOpenAPI(conf, event, file)
+		if importErr != nil {
+			return fmt.Errorf("%s in %s", importErr, event.Meta["OpenAPI"])
+		}
+
+		events = append(events, imported...)
+	}
+
+	conf.Events = events
+	return nil
+}
+
+// readOpenAPI reads the events of an OpenAPI document
+func readOpenAPI(conf *Config, base *Event, file string) ([]*Event, error) {
+	data, readErr := ioutil.ReadFile(file)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	doc := &openAPIDocument{}
+	if decodeErr := decodeOpenAPI(file, data, doc); decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") && doc.Swagger != "2.0" {
+		return nil, errors.New("Unsupported OpenAPI version")
+	}
+
+	if extErr := checkExtensions("the document", doc.Extra); extErr != nil {
+		return nil, extErr
+	}
+
+	conf.API.BinaryMediaTypes = append(
+		conf.API.BinaryMediaTypes,
+		doc.BinaryMediaTypes...,
+	)
+
+	schemes := doc.Components.SecuritySchemes
+	if doc.Swagger != "" {
+		schemes = doc.SecurityDefinitions
+	}
+
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if schemes[name] == nil {
+			continue
+		}
+
+		where := fmt.Sprintf("security scheme %s", name)
+		if extErr := checkExtensions(where, schemes[name].Extra); extErr != nil {
+			return nil, extErr
+		}
+	}
+
+	// Swagger 2 documents serve their paths under their basePath
+	basePath := strings.TrimSuffix(doc.BasePath, "/")
+	if doc.Swagger == "" {
+		basePath = ""
+	} else if doc.BasePath != "" && !strings.HasPrefix(doc.BasePath, "/") {
+		return nil, fmt.Errorf("Invalid basePath %s", doc.BasePath)
+	}
+
+	routes := make([]string, 0, len(doc.Paths))
+	for route := range doc.Paths {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	events := []*Event{}
+
+	for _, docRoute := range routes {
+		route := basePath + docRoute
+		if basePath != "" && docRoute == "/" {
+			route = basePath
+		}
+
+		if !validRoute(route) {
+			return nil, fmt.Errorf("Invalid route %s", route)
+		}
+
+		item := doc.Paths[docRoute]
+		keys := make([]string, 0, len(item))
+		for key := range item {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			method, ok := openAPIMethods[key]
+			if !ok {
+				if strings.HasPrefix(key, openAPIExtensionPrefix) {
+					return nil, fmt.Errorf(
+						"Unsupported extension %s for %s",
+						key,
+						route,
+					)
+				}
+
+				continue
+			}
+
+			op := &openAPIOperation{}
+			if decodeErr := decodeOpenAPINode(item[key], op); decodeErr != nil {
+				return nil, fmt.Errorf("Invalid operation %s %s", method, route)
+			}
+
+			event, opErr := readOpenAPIOperation(
+				conf, doc, schemes, base, route, method, op,
+			)
+			if opErr != nil {
+				return nil, fmt.Errorf("%s for %s %s", opErr, method, route)
+			}
+
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// readOpenAPIOperation converts an operation of an OpenAPI document into an
+// api event. The base event's Meta is copied to the new event, and its
+// Target is used for operations without an integration.
+func readOpenAPIOperation(
+	conf *Config,
+	doc *openAPIDocument,
+	schemes map[string]*openAPISecurityScheme,
+	base *Event,
+	route string,
+	method string,
+	op *openAPIOperation,
+) (*Event, error) {
+	if extErr := checkExtensions("the operation", op.Extra); extErr != nil {
+		return nil, extErr
+	}
+
+	event := &Event{
+		Source: APISource,
+		Target: base.Target,
+		Meta:   make(map[string]string),
+	}
+	for key, val := range base.Meta {
+		if key != "OpenAPI" {
+			event.Meta[key] = val
+		}
+	}
+	event.Meta["Route"] = route
+	if method != AnyMethod {
+		event.Meta["Method"] = method
+	}
+
+	if op.Integration != nil {
+		if op.Integration.Type != "aws_proxy" {
+			return nil, fmt.Errorf(
+				"Unsupported integration type %s",
+				op.Integration.Type,
+			)
+		}
+
+		target, uriErr := openAPIFunction(op.Integration.URI)
+		if uriErr != nil {
+			return nil, uriErr
+		}
+		event.Target = target
+
+		if version := op.Integration.PayloadFormatVersion; version != "" {
+			if version != PayloadVersion1 && version != PayloadVersion2 {
+				return nil, fmt.Errorf("Invalid payloadFormatVersion %s", version)
+			}
+
+			if version != PayloadVersion1 {
+				event.Meta["PayloadVersion"] = version
+			}
+		}
+	}
+
+	if event.Target == "" {
+		return nil, errors.New("Missing x-amazon-apigateway-integration")
+	}
+
+	if _, ok := conf.Functions[event.Target]; !ok {
+		return nil, fmt.Errorf("Unknown function %s", event.Target)
+	}
+
+	security := doc.Security
+	if op.Security != nil {
+		security = *op.Security
+	}
+
+	if authErr := readOpenAPISecurity(conf, schemes, security, event); authErr != nil {
+		return nil, authErr
+	}
+
+	return event, nil
+}
+
+// readOpenAPISecurity sets the authorizer of an event from the security
+// requirements of its operation, adding the authorizer to the API
+func readOpenAPISecurity(
+	conf *Config,
+	schemes map[string]*openAPISecurityScheme,
+	security []map[string][]string,
+	event *Event,
+) error {
+	requirements := []string{}
+	for _, requirement := range security {
+		for name := range requirement {
+			requirements = append(requirements, name)
+		}
+	}
+
+	if len(requirements) == 0 {
+		return nil
+	} else if len(requirements) > 1 {
+		return errors.New("Unsupported multiple security requirements")
+	}
+
+	name := requirements[0]
+	scheme, ok := schemes[name]
+	if !ok {
+		return fmt.Errorf("Unknown security scheme %s", name)
+	}
+
+	if scheme == nil || scheme.Authorizer == nil {
+		return fmt.Errorf(
+			"Unsupported security scheme %s without an x-amazon-apigateway-authorizer",
+			name,
+		)
+	}
+
+	var scopes []string
+	for _, requirement := range security {
+		scopes = append(scopes, requirement[name]...)
+	}
+
+	if strings.ToLower(scheme.Authorizer.Type) == "jwt" {
+		jwtAuth, jwtErr := openAPIJWTAuthorizer(name, scheme.Authorizer)
+		if jwtErr != nil {
+			return jwtErr
+		}
+
+		if existing := conf.API.JWTAuthorizer; existing != nil &&
+			(existing.Issuer != jwtAuth.Issuer ||
+				strings.Join(existing.Audiences, ",") != strings.Join(jwtAuth.Audiences, ",")) {
+			return fmt.Errorf("Security scheme %s conflicts with the JWTAuthorizer", name)
+		} else if existing == nil {
+			conf.API.JWTAuthorizer = jwtAuth
+		}
+
+		event.Meta["Authorizer"] = JWTAuthorizerName
+		if len(scopes) > 0 {
+			event.Meta["AuthorizationScopes"] = strings.Join(scopes, ",")
+		}
+
+		return nil
+	}
+
+	auth, authErr := openAPILambdaAuthorizer(name, scheme.Authorizer)
+	if authErr != nil {
+		return authErr
+	}
+
+	if existing, ok := conf.API.Authorizers[name]; ok &&
+		!reflect.DeepEqual(existing, auth) {
+		return fmt.Errorf("Security scheme %s conflicts with authorizer %s", name, name)
+	}
+
+	if conf.API.Authorizers == nil {
+		conf.API.Authorizers = make(map[string]*Authorizer)
+	}
+	conf.API.Authorizers[name] = auth
+	event.Meta["Authorizer"] = name
+
+	return nil
+}
+
+// openAPILambdaAuthorizer converts an x-amazon-apigateway-authorizer of type
+// token or request into an Authorizer
+func openAPILambdaAuthorizer(
+	name string,
+	authorizer *openAPIAuthorizer,
+) (*Authorizer, error) {
+	auth := &Authorizer{
+		Name:           name,
+		Type:           strings.ToUpper(authorizer.Type),
+		IdentitySource: DefaultIdentitySource,
+		TTL:            DefaultAuthorizerTTL,
+	}
+
+	if auth.Type != TokenAuthorizer && auth.Type != RequestAuthorizer {
+		return nil, fmt.Errorf(
+			"Unsupported authorizer type %s for security scheme %s",
+			authorizer.Type,
+			name,
+		)
+	}
+
+	function, uriErr := openAPIFunction(authorizer.AuthorizerURI)
+	if uriErr != nil {
+		return nil, fmt.Errorf("%s for security scheme %s", uriErr, name)
+	}
+	auth.Function = function
+
+	if source, ok := authorizer.IdentitySource.(string); ok && source != "" {
+		auth.IdentitySource = []string{}
+		for _, part := range strings.Split(source, ",") {
+			auth.IdentitySource = append(auth.IdentitySource, strings.TrimSpace(part))
+		}
+	} else if authorizer.IdentitySource != nil {
+		return nil, fmt.Errorf("Invalid identitySource for security scheme %s", name)
+	}
+
+	if auth.Type == TokenAuthorizer && len(auth.IdentitySource) != 1 {
+		return nil, fmt.Errorf(
+			"TOKEN security scheme %s must have one identitySource",
+			name,
+		)
+	}
+
+	if !validIdentitySources(auth.IdentitySource) {
+		return nil, fmt.Errorf(
+			"Unsupported identitySource for security scheme %s",
+			name,
+		)
+	}
+
+	if authorizer.ResultTTL != nil {
+		ttl := time.Duration(*authorizer.ResultTTL) * time.Second
+		if ttl < 0 || ttl > MaxAuthorizerTTL {
+			return nil, fmt.Errorf(
+				"Invalid authorizerResultTtlInSeconds for security scheme %s",
+				name,
+			)
+		}
+
+		auth.TTL = ttl
+	}
+
+	return auth, nil
+}
+
+// openAPIJWTAuthorizer converts an x-amazon-apigateway-authorizer of type
+// jwt into a JWTAuthorizer
+func openAPIJWTAuthorizer(
+	name string,
+	authorizer *openAPIAuthorizer,
+) (*JWTAuthorizer, error) {
+	jwtConfig := authorizer.JWTConfiguration
+	if jwtConfig.Issuer == "" || len(jwtConfig.Audience) == 0 {
+		return nil, fmt.Errorf(
+			"Security scheme %s needs a jwtConfiguration issuer and audience",
+			name,
+		)
+	}
+
+	return &JWTAuthorizer{
+		Issuer:    jwtConfig.Issuer,
+		Audiences: jwtConfig.Audience,
+	}, nil
+}
+
+// openAPIFunction returns the name of the function a Lambda integration or
+// authorizer URI invokes. The URI is either a string or a CloudFormation
+// Fn::Sub of one.
+func openAPIFunction(uri interface{}) (string, error) {
+	if sub, ok := uri.(map[interface{}]interface{}); ok {
+		uri = sub["Fn::Sub"]
+	}
+
+	uriString, ok := uri.(string)
+	if !ok {
+		return "", errors.New("Unsupported integration uri")
+	}
+
+	match := openAPIURIPattern.FindStringSubmatch(uriString)
+	if match == nil {
+		return "", fmt.Errorf("Unsupported integration uri %s", uriString)
+	}
+
+	for _, pattern := range openAPIFunctionPatterns {
+		if fnMatch := pattern.FindStringSubmatch(match[1]); fnMatch != nil {
+			return fnMatch[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("Unsupported integration uri %s", uriString)
+}
+
+// checkExtensions rejects the API Gateway extensions ladle doesn't support
+func checkExtensions(where string, extra map[string]interface{}) error {
+	unsupported := []string{}
+	for key := range extra {
+		if strings.HasPrefix(key, openAPIExtensionPrefix) {
+			unsupported = append(unsupported, key)
+		}
+	}
+
+	if len(unsupported) == 0 {
+		return nil
+	}
+
+	sort.Strings(unsupported)
+	return fmt.Errorf(
+		"Unsupported extension %s in %s",
+		strings.Join(unsupported, ", "),
+		where,
+	)
+}
+
+// decodeOpenAPI decodes a JSON or YAML OpenAPI document
+func decodeOpenAPI(file string, data []byte, doc *openAPIDocument) error {
+	if path.Ext(file) != ".json" {
+		return yaml.Unmarshal(data, doc)
+	}
+
+	var node interface{}
+	if unmarshalErr := json.Unmarshal(data, &node); unmarshalErr != nil {
+		return unmarshalErr
+	}
+
+	return decodeOpenAPINode(node, doc)
+}
+
+// decodeOpenAPINode decodes a generic node of a document into a struct
+func decodeOpenAPINode(node interface{}, out interface{}) error {
+	data, marshalErr := yaml.Marshal(node)
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	return yaml.Unmarshal(data, out)
+}
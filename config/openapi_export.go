@@ -0,0 +1,346 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OpenAPIVersion is the OpenAPI version of exported documents
+const OpenAPIVersion = "3.0.1"
+
+// OpenAPI is an OpenAPI 3 document describing the API's routes
+type OpenAPI struct {
+	OpenAPI          string                                  `json:"openapi" yaml:"openapi"`
+	Info             OpenAPIInfo                             `json:"info" yaml:"info"`
+	Servers          []OpenAPIServer                         `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Paths            map[string]map[string]*OpenAPIOperation `json:"paths" yaml:"paths"`
+	Components       *OpenAPIComponents                      `json:"components,omitempty" yaml:"components,omitempty"`
+	BinaryMediaTypes []string                                `json:"x-amazon-apigateway-binary-media-types,omitempty" yaml:"x-amazon-apigateway-binary-media-types,omitempty"`
+}
+
+// OpenAPIInfo is the info section of an OpenAPI document
+type OpenAPIInfo struct {
+	Title   string `json:"title" yaml:"title"`
+	Version string `json:"version" yaml:"version"`
+}
+
+// OpenAPIServer is a server an OpenAPI document's API is served from
+type OpenAPIServer struct {
+	URL         string `json:"url" yaml:"url"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// OpenAPIOperation is an operation of a path, invoking an api event's target
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId" yaml:"operationId"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses" yaml:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty" yaml:"security,omitempty"`
+	Integration OpenAPIIntegration         `json:"x-amazon-apigateway-integration" yaml:"x-amazon-apigateway-integration"`
+}
+
+// OpenAPIParameter is a path parameter of an operation
+type OpenAPIParameter struct {
+	Name     string            `json:"name" yaml:"name"`
+	In       string            `json:"in" yaml:"in"`
+	Required bool              `json:"required" yaml:"required"`
+	Schema   map[string]string `json:"schema" yaml:"schema"`
+}
+
+// OpenAPIResponse is a response of an operation
+type OpenAPIResponse struct {
+	Description string `json:"description" yaml:"description"`
+}
+
+// OpenAPIIntegration is the x-amazon-apigateway-integration of an operation
+type OpenAPIIntegration struct {
+	Type                 string `json:"type" yaml:"type"`
+	HTTPMethod           string `json:"httpMethod" yaml:"httpMethod"`
+	URI                  string `json:"uri" yaml:"uri"`
+	PayloadFormatVersion string `json:"payloadFormatVersion" yaml:"payloadFormatVersion"`
+}
+
+// OpenAPIComponents holds the security schemes of an OpenAPI document
+type OpenAPIComponents struct {
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes" yaml:"securitySchemes"`
+}
+
+// OpenAPISecurityScheme is a security scheme backed by one of the API's
+// authorizers
+type OpenAPISecurityScheme struct {
+	Type       string                  `json:"type" yaml:"type"`
+	Name       string                  `json:"name,omitempty" yaml:"name,omitempty"`
+	In         string                  `json:"in,omitempty" yaml:"in,omitempty"`
+	Flows      *OpenAPIOAuthFlows      `json:"flows,omitempty" yaml:"flows,omitempty"`
+	AuthType   string                  `json:"x-amazon-apigateway-authtype,omitempty" yaml:"x-amazon-apigateway-authtype,omitempty"`
+	Authorizer OpenAPISchemeAuthorizer `json:"x-amazon-apigateway-authorizer" yaml:"x-amazon-apigateway-authorizer"`
+}
+
+// OpenAPIOAuthFlows is the flows of an oauth2 security scheme. The spec
+// requires it, but tokens for jwt authorizers come from outside the API, so
+// like API Gateway's own exports it's always empty.
+type OpenAPIOAuthFlows struct{}
+
+// OpenAPISchemeAuthorizer is the x-amazon-apigateway-authorizer of a
+// security scheme
+type OpenAPISchemeAuthorizer struct {
+	Type             string                   `json:"type" yaml:"type"`
+	AuthorizerURI    string                   `json:"authorizerUri,omitempty" yaml:"authorizerUri,omitempty"`
+	IdentitySource   string                   `json:"identitySource" yaml:"identitySource"`
+	ResultTTL        *int                     `json:"authorizerResultTtlInSeconds,omitempty" yaml:"authorizerResultTtlInSeconds,omitempty"`
+	JWTConfiguration *OpenAPIJWTConfiguration `json:"jwtConfiguration,omitempty" yaml:"jwtConfiguration,omitempty"`
+}
+
+// OpenAPIJWTConfiguration is the jwtConfiguration of a jwt authorizer
+type OpenAPIJWTConfiguration struct {
+	Issuer   string   `json:"issuer" yaml:"issuer"`
+	Audience []string `json:"audience" yaml:"audience"`
+}
+
+// ExportOpenAPI builds an OpenAPI document of the config's api events. Each
+// event's methods become operations with its path parameters and an
+// integration invoking its target. Servers are listed under baseURL, one per
+// stage. Events for any method are only listed under API Gateway's any method
+// extension, which standard tools don't know, so importing the document into
+// API Gateway doesn't create extra methods.
+func ExportOpenAPI(conf *Config, title string, baseURL string) *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI:          OpenAPIVersion,
+		Info:             OpenAPIInfo{Title: title, Version: "1.0"},
+		Servers:          openAPIServers(conf, baseURL),
+		Paths:            make(map[string]map[string]*OpenAPIOperation),
+		BinaryMediaTypes: conf.API.BinaryMediaTypes,
+	}
+
+	schemes := make(map[string]*OpenAPISecurityScheme)
+	operationIDs := make(map[string]bool)
+
+	for _, event := range conf.Events {
+		if event.Source != APISource {
+			continue
+		}
+
+		route := event.Meta["Route"]
+		if doc.Paths[route] == nil {
+			doc.Paths[route] = make(map[string]*OpenAPIOperation)
+		}
+
+		methods := event.Methods()
+		if methods == nil {
+			methods = []string{AnyMethod}
+		}
+
+		for _, method := range methods {
+			op := exportOpenAPIOperation(conf, event, method)
+
+			// operation ids must be unique, so number any repeats
+			id := op.OperationID
+			for i := 2; operationIDs[op.OperationID]; i++ {
+				op.OperationID = fmt.Sprintf("%s%d", id, i)
+			}
+			operationIDs[op.OperationID] = true
+
+			if name := event.Meta["Authorizer"]; name != "" {
+				if scheme := exportSecurityScheme(conf, name); scheme != nil {
+					schemes[name] = scheme
+				}
+			}
+
+			key := strings.ToLower(method)
+			if method == AnyMethod {
+				key = openAPIAnyMethod
+			}
+			doc.Paths[route][key] = op
+		}
+	}
+
+	if len(schemes) > 0 {
+		doc.Components = &OpenAPIComponents{SecuritySchemes: schemes}
+	}
+
+	return doc
+}
+
+// exportOpenAPIOperation builds the operation for one method of an api event
+func exportOpenAPIOperation(
+	conf *Config,
+	event *Event,
+	method string,
+) *OpenAPIOperation {
+	route := event.Meta["Route"]
+
+	op := &OpenAPIOperation{
+		OperationID: openAPIOperationID(method, route),
+		Responses: map[string]OpenAPIResponse{
+			"default": {Description: fmt.Sprintf("Response from %s", event.Target)},
+		},
+		Integration: OpenAPIIntegration{
+			Type:                 "aws_proxy",
+			HTTPMethod:           "POST",
+			URI:                  lambdaInvocationURI(conf, event.Target),
+			PayloadFormatVersion: event.PayloadVersion(),
+		},
+	}
+
+	for _, part := range strings.Split(route, "/") {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			continue
+		}
+
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name:     strings.TrimSuffix(strings.Trim(part, "{}"), "+"),
+			In:       "path",
+			Required: true,
+			Schema:   map[string]string{"type": "string"},
+		})
+	}
+
+	if name := event.Meta["Authorizer"]; name != "" {
+		scopes := []string{}
+		if event.Meta["AuthorizationScopes"] != "" {
+			scopes = strings.Split(event.Meta["AuthorizationScopes"], ",")
+		}
+
+		op.Security = []map[string][]string{{name: scopes}}
+	}
+
+	return op
+}
+
+// exportSecurityScheme builds the security scheme of an authorizer, or nil
+// if the API doesn't define it
+func exportSecurityScheme(conf *Config, name string) *OpenAPISecurityScheme {
+	if name == JWTAuthorizerName {
+		jwtAuth := conf.API.JWTAuthorizer
+		if jwtAuth == nil {
+			return nil
+		}
+
+		// API Gateway imports jwt authorizers from oauth2 schemes
+		return &OpenAPISecurityScheme{
+			Type:  "oauth2",
+			Flows: &OpenAPIOAuthFlows{},
+			Authorizer: OpenAPISchemeAuthorizer{
+				Type:           "jwt",
+				IdentitySource: "$request.header.Authorization",
+				JWTConfiguration: &OpenAPIJWTConfiguration{
+					Issuer:   jwtAuth.Issuer,
+					Audience: jwtAuth.Audiences,
+				},
+			},
+		}
+	}
+
+	auth, ok := conf.API.Authorizers[name]
+	if !ok {
+		return nil
+	}
+
+	ttl := int(auth.TTL.Seconds())
+	scheme := &OpenAPISecurityScheme{
+		Type:     "apiKey",
+		Name:     "Unused",
+		In:       "header",
+		AuthType: "custom",
+		Authorizer: OpenAPISchemeAuthorizer{
+			Type:           strings.ToLower(auth.Type),
+			AuthorizerURI:  lambdaInvocationURI(conf, auth.Function),
+			IdentitySource: strings.Join(auth.IdentitySource, ", "),
+			ResultTTL:      &ttl,
+		},
+	}
+
+	// API keys name the header or query parameter they're sent in, which is
+	// the authorizer's first identity source
+	if len(auth.IdentitySource) > 0 {
+		source := auth.IdentitySource[0]
+		for in, prefix := range openAPIIdentitySources {
+			if strings.HasPrefix(source, prefix) {
+				scheme.Name = strings.TrimPrefix(source, prefix)
+				scheme.In = in
+			}
+		}
+	}
+
+	return scheme
+}
+
+// openAPIIdentitySources maps where API keys are sent to the prefix of the
+// identity sources they're read from
+var openAPIIdentitySources = map[string]string{
+	"header": "method.request.header.",
+	"query":  "method.request.querystring.",
+}
+
+// openAPIServers lists the server urls of the API, one for each stage
+func openAPIServers(conf *Config, baseURL string) []OpenAPIServer {
+	if baseURL == "" {
+		return nil
+	}
+
+	if len(conf.API.Stages) == 0 {
+		return []OpenAPIServer{{URL: baseURL}}
+	}
+
+	servers := []OpenAPIServer{}
+	if conf.API.Stage != "" {
+		servers = append(servers, OpenAPIServer{
+			URL:         baseURL,
+			Description: conf.API.Stage,
+		})
+	}
+
+	names := make([]string, 0, len(conf.API.Stages))
+	for name := range conf.API.Stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		servers = append(servers, OpenAPIServer{
+			URL:         baseURL + "/" + name,
+			Description: name,
+		})
+	}
+
+	return servers
+}
+
+// openAPIOperationID derives an operation id, like getUsersById, from a
+// method and route
+func openAPIOperationID(method string, route string) string {
+	id := strings.ToLower(method)
+
+	for _, part := range strings.Split(route, "/") {
+		if part == "" {
+			continue
+		}
+
+		if strings.HasPrefix(part, "{") {
+			id += "By"
+			part = strings.TrimSuffix(strings.Trim(part, "{}"), "+")
+		}
+
+		for _, word := range strings.FieldsFunc(part, isIDSeparator) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+
+	return id
+}
+
+// isIDSeparator returns true for characters dropped from operation ids
+func isIDSeparator(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+}
+
+// lambdaInvocationURI returns the API Gateway integration uri that invokes a
+// function
+func lambdaInvocationURI(conf *Config, function string) string {
+	return fmt.Sprintf(
+		"arn:aws:apigateway:%s:lambda:path/2015-03-31/functions/%s/invocations",
+		conf.Region,
+		conf.FunctionArn(&Function{Name: function}),
+	)
+}
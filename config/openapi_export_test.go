@@ -0,0 +1,318 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	conf := &Config{
+		Region:    "eu-west-1",
+		AccountID: "000000000000",
+		Functions: map[string]*Function{
+			"Users": &Function{Name: "Users"},
+			"Auth":  &Function{Name: "Auth"},
+		},
+		API: API{
+			Stage: "dev",
+			Stages: map[string]*Stage{
+				"dev": &Stage{Name: "dev"},
+				"v1":  &Stage{Name: "v1"},
+			},
+			Authorizers: map[string]*Authorizer{
+				"Token": &Authorizer{
+					Name:           "Token",
+					Function:       "Auth",
+					Type:           TokenAuthorizer,
+					IdentitySource: DefaultIdentitySource,
+					TTL:            DefaultAuthorizerTTL,
+				},
+			},
+		},
+		Events: []*Event{
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route":      "/users/{id}",
+					"Method":     "GET,DELETE",
+					"Authorizer": "Token",
+				},
+			},
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta:   map[string]string{"Route": "/files/{path+}"},
+			},
+			&Event{Source: "Schedule", Target: "Users"},
+		},
+	}
+
+	doc := ExportOpenAPI(conf, "users", "http://localhost:3001")
+
+	assert.Equal(t, OpenAPIVersion, doc.OpenAPI)
+	assert.Equal(t, "users", doc.Info.Title)
+	assert.Equal(
+		t,
+		[]OpenAPIServer{
+			{URL: "http://localhost:3001", Description: "dev"},
+			{URL: "http://localhost:3001/dev", Description: "dev"},
+			{URL: "http://localhost:3001/v1", Description: "v1"},
+		},
+		doc.Servers,
+	)
+	assert.Len(t, doc.Paths, 2)
+
+	get := doc.Paths["/users/{id}"]["get"]
+	assert.Equal(t, "getUsersById", get.OperationID)
+	assert.Equal(
+		t,
+		[]OpenAPIParameter{{
+			Name:     "id",
+			In:       "path",
+			Required: true,
+			Schema:   map[string]string{"type": "string"},
+		}},
+		get.Parameters,
+	)
+	assert.Equal(t, []map[string][]string{{"Token": {}}}, get.Security)
+	assert.Equal(
+		t,
+		OpenAPIIntegration{
+			Type:                 "aws_proxy",
+			HTTPMethod:           "POST",
+			URI:                  "arn:aws:apigateway:eu-west-1:lambda:path/2015-03-31/functions/arn:aws:lambda:eu-west-1:000000000000:function:Users/invocations",
+			PayloadFormatVersion: PayloadVersion1,
+		},
+		get.Integration,
+	)
+	assert.Equal(t, "deleteUsersById", doc.Paths["/users/{id}"]["delete"].OperationID)
+
+	files := doc.Paths["/files/{path+}"][openAPIAnyMethod]
+	assert.Equal(t, "anyFilesByPath", files.OperationID)
+	assert.Equal(t, "path", files.Parameters[0].Name)
+	assert.Nil(t, files.Security)
+
+	scheme := doc.Components.SecuritySchemes["Token"]
+	assert.Equal(t, "Authorization", scheme.Name)
+	assert.Equal(t, "token", scheme.Authorizer.Type)
+	assert.Equal(t, "method.request.header.Authorization", scheme.Authorizer.IdentitySource)
+}
+
+func TestExportOpenAPIRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dir, dirErr := ioutil.TempDir("", "ladle-openapi")
+	assert.Nil(t, dirErr)
+	defer os.RemoveAll(dir)
+
+	conf := &Config{
+		Region:    DefaultRegion,
+		AccountID: DefaultAccountID,
+		Functions: map[string]*Function{"Users": &Function{Name: "Users"}},
+		API: API{
+			JWTAuthorizer: &JWTAuthorizer{
+				Issuer:    "https://issuer.example.com",
+				Audiences: []string{"web"},
+			},
+			Authorizers: map[string]*Authorizer{
+				"Token": &Authorizer{
+					Name:           "Token",
+					Function:       "Users",
+					Type:           TokenAuthorizer,
+					IdentitySource: DefaultIdentitySource,
+					TTL:            time.Minute,
+				},
+			},
+		},
+		Events: []*Event{
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route":               "/users",
+					"Method":              "GET",
+					"Authorizer":          JWTAuthorizerName,
+					"AuthorizationScopes": "read",
+				},
+			},
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route":          "/users/{id}",
+					"Method":         "PUT",
+					"PayloadVersion": PayloadVersion2,
+					"Authorizer":     "Token",
+				},
+			},
+		},
+	}
+
+	doc := ExportOpenAPI(conf, "users", "")
+	assert.Equal(t, "oauth2", doc.Components.SecuritySchemes[JWTAuthorizerName].Type)
+
+	data, marshalErr := json.Marshal(doc)
+	assert.Nil(t, marshalErr)
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, "openapi.json"), data, 0644))
+
+	imported := &Config{
+		Functions: conf.Functions,
+		Events: []*Event{
+			&Event{Source: APISource, Meta: map[string]string{"OpenAPI": "openapi.json"}},
+		},
+	}
+	assert.Nil(t, importOpenAPI(imported, dir))
+
+	assert.Equal(t, conf.Events, imported.Events)
+	assert.Equal(t, conf.API.Authorizers, imported.API.Authorizers)
+	assert.Equal(t, conf.API.JWTAuthorizer, imported.API.JWTAuthorizer)
+}
+
+func TestExportOpenAPIValid(t *testing.T) {
+	t.Parallel()
+
+	conf := &Config{
+		Region:    DefaultRegion,
+		AccountID: DefaultAccountID,
+		Functions: map[string]*Function{"Users": &Function{Name: "Users"}},
+		API: API{
+			JWTAuthorizer: &JWTAuthorizer{
+				Issuer:    "https://issuer.example.com",
+				Audiences: []string{"web"},
+			},
+			Authorizers: map[string]*Authorizer{
+				"Token": &Authorizer{
+					Name:           "Token",
+					Function:       "Users",
+					Type:           TokenAuthorizer,
+					IdentitySource: DefaultIdentitySource,
+				},
+				"Tenant": &Authorizer{
+					Name:     "Tenant",
+					Function: "Users",
+					Type:     RequestAuthorizer,
+					IdentitySource: []string{
+						"method.request.querystring.tenant",
+						"method.request.header.Authorization",
+					},
+				},
+			},
+		},
+		Events: []*Event{
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route":      "/users",
+					"Method":     "GET",
+					"Authorizer": JWTAuthorizerName,
+				},
+			},
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route":      "/users/{id}",
+					"Method":     "PUT",
+					"Authorizer": "Token",
+				},
+			},
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route":      "/tenants",
+					"Method":     "GET",
+					"Authorizer": "Tenant",
+				},
+			},
+		},
+	}
+
+	doc := ExportOpenAPI(conf, "users", "http://localhost:3001")
+
+	tenant := doc.Components.SecuritySchemes["Tenant"]
+	assert.Equal(t, "query", tenant.In)
+	assert.Equal(t, "tenant", tenant.Name)
+
+	data, marshalErr := json.Marshal(doc)
+	assert.Nil(t, marshalErr)
+
+	var exported map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &exported))
+	assert.Empty(t, openAPISpecErrors(exported))
+}
+
+// openAPISpecErrors checks the fields the OpenAPI 3 spec requires of a
+// document, its operations and its security schemes
+func openAPISpecErrors(doc map[string]interface{}) []string {
+	errs := []string{}
+
+	info, _ := doc["info"].(map[string]interface{})
+	if info["title"] == nil || info["version"] == nil {
+		errs = append(errs, "info needs a title and version")
+	}
+
+	schemes := map[string]interface{}{}
+	if components, ok := doc["components"].(map[string]interface{}); ok {
+		schemes, _ = components["securitySchemes"].(map[string]interface{})
+	}
+
+	for name, raw := range schemes {
+		scheme, _ := raw.(map[string]interface{})
+		switch scheme["type"] {
+		case "apiKey":
+			in := scheme["in"]
+			if scheme["name"] == nil ||
+				(in != "header" && in != "query" && in != "cookie") {
+				errs = append(errs, name+" needs a name and a valid in")
+			}
+		case "http":
+			if scheme["scheme"] == nil {
+				errs = append(errs, name+" needs a scheme")
+			}
+		case "oauth2":
+			if _, ok := scheme["flows"].(map[string]interface{}); !ok {
+				errs = append(errs, name+" needs flows")
+			}
+		case "openIdConnect":
+			if scheme["openIdConnectUrl"] == nil {
+				errs = append(errs, name+" needs an openIdConnectUrl")
+			}
+		default:
+			errs = append(errs, name+" has an invalid type")
+		}
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	for route, raw := range paths {
+		item, _ := raw.(map[string]interface{})
+		for method, raw := range item {
+			op, _ := raw.(map[string]interface{})
+			if op["responses"] == nil {
+				errs = append(errs, method+" "+route+" needs responses")
+			}
+
+			security, _ := op["security"].([]interface{})
+			for _, raw := range security {
+				requirement, _ := raw.(map[string]interface{})
+				for name := range requirement {
+					if schemes[name] == nil {
+						errs = append(errs, method+" "+route+" uses unknown "+name)
+					}
+				}
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	conf, err := ParsePath("fixtures/openapi.confl")
+	assert.Nil(t, err)
+
+	assert.Equal(
+		t,
+		[]*Event{
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route":      "/users",
+					"Method":     "GET",
+					"Authorizer": "Auth",
+					"CORS":       "false",
+				},
+			},
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route":               "/users",
+					"Method":              "POST",
+					"PayloadVersion":      PayloadVersion2,
+					"Authorizer":          JWTAuthorizerName,
+					"AuthorizationScopes": "write",
+					"CORS":                "false",
+				},
+			},
+			&Event{
+				Source: APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route": "/users/{id+}",
+					"CORS":  "false",
+				},
+			},
+		},
+		conf.Events,
+	)
+
+	assert.Equal(
+		t,
+		[]string{"application/pdf", "image/png"},
+		conf.API.BinaryMediaTypes,
+	)
+	assert.Equal(
+		t,
+		map[string]*Authorizer{
+			"Auth": &Authorizer{
+				Name:     "Auth",
+				Function: "Auth",
+				Type:     RequestAuthorizer,
+				IdentitySource: []string{
+					"method.request.header.Authorization",
+					"method.request.querystring.tenant",
+				},
+				TTL: 60 * time.Second,
+			},
+		},
+		conf.API.Authorizers,
+	)
+	assert.Equal(
+		t,
+		&JWTAuthorizer{
+			Issuer:    "https://issuer.example.com",
+			Audiences: []string{"web"},
+		},
+		conf.API.JWTAuthorizer,
+	)
+}
+
+func TestImportSwagger(t *testing.T) {
+	t.Parallel()
+
+	conf, err := ParsePath("fixtures/swagger.confl")
+	assert.Nil(t, err)
+
+	assert.Equal(
+		t,
+		[]*Event{
+			&Event{
+				Source: APISource,
+				Target: "Admin",
+				Meta: map[string]string{
+					"Route":      "/v1/orders",
+					"Method":     "DELETE",
+					"Authorizer": "Token",
+				},
+			},
+			&Event{
+				Source: APISource,
+				Target: "Orders",
+				Meta:   map[string]string{"Route": "/v1/orders", "Method": "GET"},
+			},
+		},
+		conf.Events,
+	)
+	assert.Equal(t, TokenAuthorizer, conf.API.Authorizers["Token"].Type)
+	assert.Equal(t, "Admin", conf.API.Authorizers["Token"].Function)
+}
+
+func TestOpenAPIFunction(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		uri     interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			"a lambda arn",
+			"arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:123456789012:function:Users/invocations",
+			"Users",
+			false,
+		},
+		{
+			"an aliased lambda arn",
+			"arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:123456789012:function:Users:live/invocations",
+			"Users",
+			false,
+		},
+		{
+			"a cloudformation reference",
+			map[interface{}]interface{}{
+				"Fn::Sub": "arn:aws:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/${Users.Arn}/invocations",
+			},
+			"Users",
+			false,
+		},
+		{"an http uri", "https://example.com/users", "", true},
+		{
+			"a stage variable",
+			"arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/${stageVariables.fn}/invocations",
+			"",
+			true,
+		},
+		{"a missing uri", nil, "", true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := openAPIFunction(test.uri)
+			assert.Equal(t, test.wantErr, err != nil)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/nalanj/confl"
+)
+
+// Stage is a stage of the API, served under a path prefix of its name
+type Stage struct {
+	// Name is the stage name, like dev or v1
+	Name string
+
+	// Variables are the stage variables passed to functions
+	Variables map[string]string
+}
+
+// stageNamePattern matches the names API Gateway allows for stages
+var stageNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// stageVariablePattern matches the names API Gateway allows for stage
+// variables
+var stageVariablePattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// readStages reads the Stages section of the API
+func readStages(stagesNode confl.Node) (map[string]*Stage, error) {
+	if stagesNode.Type() != confl.MapType {
+		return nil, errors.New("Invalid Stages section")
+	}
+
+	stages := make(map[string]*Stage)
+
+	for _, pair := range confl.KVPairs(stagesNode) {
+		stage, stageErr := readStage(pair.Key.Value(), pair.Value)
+		if stageErr != nil {
+			return nil, stageErr
+		}
+
+		stages[stage.Name] = stage
+	}
+
+	return stages, nil
+}
+
+// readStage reads a single stage
+func readStage(name string, stageNode confl.Node) (*Stage, error) {
+	if !stageNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("Invalid stage name %q", name)
+	}
+
+	if stageNode.Type() != confl.MapType {
+		return nil, fmt.Errorf("Invalid stage %s", name)
+	}
+
+	stage := &Stage{Name: name}
+
+	for _, pair := range confl.KVPairs(stageNode) {
+		switch pair.Key.Value() {
+		case "Variables":
+			variables, varsErr := readStageVariables(pair.Value)
+			if varsErr != nil {
+				return nil, fmt.Errorf("%s for stage %s", varsErr, name)
+			}
+
+			stage.Variables = variables
+		default:
+			return nil, fmt.Errorf("Unknown key %s for stage %s", pair.Key.Value(), name)
+		}
+	}
+
+	return stage, nil
+}
+
+// readStageVariables reads the Variables of a stage
+func readStageVariables(varsNode confl.Node) (map[string]string, error) {
+	if varsNode.Type() != confl.MapType {
+		return nil, errors.New("Invalid Variables")
+	}
+
+	variables := make(map[string]string)
+
+	for _, pair := range confl.KVPairs(varsNode) {
+		key := pair.Key.Value()
+		if !stageVariablePattern.MatchString(key) {
+			return nil, fmt.Errorf("Invalid stage variable name %q", key)
+		}
+
+		if !confl.IsText(pair.Value) && pair.Value.Type() != confl.NumberType {
+			return nil, fmt.Errorf("Invalid value for stage variable %s", key)
+		}
+
+		variables[key] = pair.Value.Value()
+	}
+
+	return variables, nil
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/nalanj/ladle/config"
+)
+
+// BuildFunction runs go build against the given function definition,
+// writing the executable to where the runtime looks for it
+func BuildFunction(conf *config.Config, f *config.Function) error {
+	args := []string{
+		"build",
+		"-o",
+		conf.FunctionExecutable(f),
+		f.Package,
+	}
+	fmt.Printf("Fn %s: %s %s\n", f.Name, "go", strings.Join(args, " "))
+	cmd := exec.Command("go", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
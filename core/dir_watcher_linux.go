@@ -0,0 +1,218 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// inotifyMask is the set of inotify events that count as a change
+	inotifyMask = syscall.IN_CLOSE_WRITE |
+		syscall.IN_CREATE |
+		syscall.IN_DELETE |
+		syscall.IN_MOVED_FROM |
+		syscall.IN_MOVED_TO
+)
+
+// dirWatcher watches directories for changed files using inotify
+type dirWatcher struct {
+	// Events receives the paths of changed files
+	Events chan string
+
+	// fd is the non-blocking inotify file descriptor
+	fd int
+
+	// epfd is the epoll file descriptor read waits on for inotify events
+	// and wake-ups
+	epfd int
+
+	// wake is a pipe Close writes to so read stops waiting
+	wake [2]int
+
+	// closed is closed when the watcher is closed, and done once read has
+	// returned
+	closed    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// dirs maps watch descriptors to their directories
+	dirs map[int32]string
+
+	// dirsMtx guards dirs
+	dirsMtx sync.Mutex
+}
+
+// newDirWatcher returns a new dirWatcher that isn't watching anything yet
+func newDirWatcher() (*dirWatcher, error) {
+	fd, initErr := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if initErr != nil {
+		return nil, os.NewSyscallError("inotify_init1", initErr)
+	}
+
+	w := &dirWatcher{
+		Events: make(chan string),
+		fd:     fd,
+		epfd:   -1,
+		wake:   [2]int{-1, -1},
+		dirs:   make(map[int32]string),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if pollErr := w.initPoll(); pollErr != nil {
+		w.closeFds()
+		return nil, pollErr
+	}
+
+	go w.read()
+
+	return w, nil
+}
+
+// initPoll sets up the epoll instance read waits on, watching the inotify
+// fd and the read end of the wake pipe
+func (w *dirWatcher) initPoll() error {
+	if pipeErr := syscall.Pipe2(
+		w.wake[:],
+		syscall.O_CLOEXEC|syscall.O_NONBLOCK,
+	); pipeErr != nil {
+		return os.NewSyscallError("pipe2", pipeErr)
+	}
+
+	epfd, epollErr := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if epollErr != nil {
+		return os.NewSyscallError("epoll_create1", epollErr)
+	}
+	w.epfd = epfd
+
+	for _, fd := range []int{w.fd, w.wake[0]} {
+		event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+		if ctlErr := syscall.EpollCtl(
+			epfd,
+			syscall.EPOLL_CTL_ADD,
+			fd,
+			&event,
+		); ctlErr != nil {
+			return os.NewSyscallError("epoll_ctl", ctlErr)
+		}
+	}
+
+	return nil
+}
+
+// Add watches the given directory
+func (w *dirWatcher) Add(dir string) error {
+	wd, addErr := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if addErr != nil {
+		return os.NewSyscallError("inotify_add_watch", addErr)
+	}
+
+	w.dirsMtx.Lock()
+	w.dirs[int32(wd)] = dir
+	w.dirsMtx.Unlock()
+
+	return nil
+}
+
+// Close stops watching, waiting for the reader to stop before closing the
+// watcher's file descriptors so they can't be reused under it
+func (w *dirWatcher) Close() error {
+	var closeErr error
+
+	w.closeOnce.Do(func() {
+		close(w.closed)
+		syscall.Write(w.wake[1], []byte{0})
+		<-w.done
+
+		closeErr = w.closeFds()
+	})
+
+	return closeErr
+}
+
+// closeFds closes the watcher's open file descriptors
+func (w *dirWatcher) closeFds() error {
+	var closeErr error
+	for _, fd := range []int{w.fd, w.epfd, w.wake[0], w.wake[1]} {
+		if fd < 0 {
+			continue
+		}
+
+		if err := syscall.Close(fd); err != nil && closeErr == nil {
+			closeErr = os.NewSyscallError("close", err)
+		}
+	}
+
+	return closeErr
+}
+
+// read reads inotify events and sends them to Events until the watcher is
+// closed
+func (w *dirWatcher) read() {
+	defer close(w.done)
+	defer close(w.Events)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	events := make([]syscall.EpollEvent, 2)
+	for {
+		ready, waitErr := syscall.EpollWait(w.epfd, events, -1)
+		if waitErr == syscall.EINTR {
+			continue
+		}
+		if waitErr != nil {
+			return
+		}
+
+		for _, event := range events[:ready] {
+			if int(event.Fd) == w.wake[0] {
+				return
+			}
+		}
+
+		if !w.readEvents(buf) {
+			return
+		}
+	}
+}
+
+// readEvents reads the pending inotify events and sends them to Events,
+// returning false if the watcher was closed or can't be read
+func (w *dirWatcher) readEvents(buf []byte) bool {
+	for {
+		n, readErr := syscall.Read(w.fd, buf)
+		if readErr == syscall.EINTR {
+			continue
+		}
+		if readErr == syscall.EAGAIN {
+			return true
+		}
+		if readErr != nil || n <= 0 {
+			return false
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := buf[nameStart : nameStart+int(event.Len)]
+			offset = nameStart + int(event.Len)
+
+			w.dirsMtx.Lock()
+			dir, ok := w.dirs[event.Wd]
+			w.dirsMtx.Unlock()
+
+			if !ok || len(name) == 0 {
+				continue
+			}
+
+			select {
+			case w.Events <- filepath.Join(dir, string(bytes.TrimRight(name, "\x00"))):
+			case <-w.closed:
+				return false
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+//go:build !linux
+// +build !linux
+
+package core
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// dirWatcher watches directories for changed files by polling their
+// modification times
+type dirWatcher struct {
+	// Events receives the paths of changed files
+	Events chan string
+
+	// mtimes maps watched files to their last known modification times
+	mtimes map[string]time.Time
+
+	// dirs is the set of watched directories
+	dirs map[string]bool
+
+	// mtx guards mtimes and dirs
+	mtx sync.Mutex
+
+	// closed is closed when the watcher is closed, and done once poll has
+	// returned
+	closed    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newDirWatcher returns a new dirWatcher that isn't watching anything yet
+func newDirWatcher() (*dirWatcher, error) {
+	w := &dirWatcher{
+		Events: make(chan string),
+		mtimes: make(map[string]time.Time),
+		dirs:   make(map[string]bool),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go w.poll()
+
+	return w, nil
+}
+
+// Add watches the given directory
+func (w *dirWatcher) Add(dir string) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.dirs[dir] = true
+	w.scan(dir, false)
+
+	return nil
+}
+
+// Close stops watching, waiting for the poller to stop
+func (w *dirWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.closed)
+		<-w.done
+	})
+
+	return nil
+}
+
+// poll checks the watched directories every second until the watcher is
+// closed
+func (w *dirWatcher) poll() {
+	defer close(w.done)
+	defer close(w.Events)
+
+	for {
+		select {
+		case <-w.closed:
+			return
+		case <-time.After(1 * time.Second):
+		}
+
+		w.mtx.Lock()
+		changed := []string{}
+		for dir := range w.dirs {
+			changed = append(changed, w.scan(dir, true)...)
+		}
+		w.mtx.Unlock()
+
+		for _, path := range changed {
+			select {
+			case w.Events <- path:
+			case <-w.closed:
+				return
+			}
+		}
+	}
+}
+
+// scan records the modification times of the files in dir and returns the
+// files that changed since the last scan, including new files if report is
+// true. mtx must be held.
+func (w *dirWatcher) scan(dir string, report bool) []string {
+	infos, readErr := ioutil.ReadDir(dir)
+	if readErr != nil {
+		return nil
+	}
+
+	changed := []string{}
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+
+		mtime, ok := w.mtimes[path]
+		if (ok && info.ModTime().After(mtime)) || (!ok && report) {
+			changed = append(changed, path)
+		}
+
+		w.mtimes[path] = info.ModTime()
+	}
+
+	return changed
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nalanj/ladle/config"
+)
+
+const (
+	// functionVersion is the version every function runs as
+	functionVersion = "$LATEST"
+)
+
+// functionEnv builds the environment for the function's process from the
+// current environment, the configured function environment and the standard
+// runtime environment. Runtime variables already set in the current
+// environment are dropped unless the config opts in to inheriting them.
+func functionEnv(
+	c *config.Config,
+	f *config.Function,
+	startTime time.Time,
+) []string {
+	runtimeEnv := runtimeEnvironment(c, f, startTime)
+
+	env := []string{}
+	for _, pair := range os.Environ() {
+		name := strings.SplitN(pair, "=", 2)[0]
+		if _, ok := runtimeEnv[name]; ok {
+			if !c.InheritRuntimeEnvironment {
+				continue
+			}
+
+			delete(runtimeEnv, name)
+		}
+
+		env = append(env, pair)
+	}
+
+	env = append(env, sortedEnv(c.FunctionEnvironment(f))...)
+	return append(env, sortedEnv(runtimeEnv)...)
+}
+
+// runtimeEnvironment returns the standard variables the Lambda runtime sets
+// for the given function
+func runtimeEnvironment(
+	c *config.Config,
+	f *config.Function,
+	startTime time.Time,
+) map[string]string {
+	return map[string]string{
+		"_HANDLER":                        f.Name,
+		"AWS_DEFAULT_REGION":              c.Region,
+		"AWS_EXECUTION_ENV":               "AWS_Lambda_go1.x",
+		"AWS_LAMBDA_FUNCTION_MEMORY_SIZE": strconv.Itoa(functionMemorySize(f)),
+		"AWS_LAMBDA_FUNCTION_NAME":        f.Name,
+		"AWS_LAMBDA_FUNCTION_VERSION":     functionVersion,
+		"AWS_LAMBDA_LOG_GROUP_NAME":       fmt.Sprintf("/aws/lambda/%s", f.Name),
+		"AWS_LAMBDA_LOG_STREAM_NAME":      logStreamName(startTime),
+		"AWS_REGION":                      c.Region,
+		"LAMBDA_RUNTIME_DIR":              c.RuntimeDir(),
+		"LAMBDA_TASK_ROOT":                taskRoot(c, f),
+		"TZ":                              ":UTC",
+	}
+}
+
+// taskRoot returns the absolute path of the directory holding the function's
+// executable, as Lambda's task root is always absolute
+func taskRoot(c *config.Config, f *config.Function) string {
+	dir := filepath.Dir(c.FunctionExecutable(f))
+
+	abs, absErr := filepath.Abs(dir)
+	if absErr != nil {
+		return dir
+	}
+
+	return abs
+}
+
+// logStreamName returns a log stream name for an instance started at the
+// given time
+func logStreamName(startTime time.Time) string {
+	return fmt.Sprintf(
+		"%s/[%s]%s",
+		startTime.UTC().Format("2006/01/02"),
+		functionVersion,
+		randomHex(16),
+	)
+}
+
+// sortedEnv converts a map of variables to NAME=value pairs sorted by name
+func sortedEnv(vars map[string]string) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		env = append(env, fmt.Sprintf("%s=%s", name, vars[name]))
+	}
+
+	return env
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nalanj/ladle/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeEnvironment(t *testing.T) {
+	t.Parallel()
+
+	c := &config.Config{Path: "../ladle.confl", Region: "eu-west-1"}
+	f := &config.Function{Name: "Echo", MemorySize: 512}
+	env := runtimeEnvironment(c, f, time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC))
+
+	for _, name := range config.RuntimeEnvironmentVariables {
+		assert.Contains(t, env, name)
+	}
+
+	assert.Equal(t, "Echo", env["AWS_LAMBDA_FUNCTION_NAME"])
+	assert.Equal(t, "512", env["AWS_LAMBDA_FUNCTION_MEMORY_SIZE"])
+	assert.Equal(t, "eu-west-1", env["AWS_REGION"])
+	assert.Equal(t, "/aws/lambda/Echo", env["AWS_LAMBDA_LOG_GROUP_NAME"])
+	assert.True(t, filepath.IsAbs(env["LAMBDA_TASK_ROOT"]))
+	assert.Equal(t, ".ladle", filepath.Base(env["LAMBDA_TASK_ROOT"]))
+	assert.Regexp(
+		t,
+		`^2019/05/01/\[\$LATEST\][0-9a-f]{32}$`,
+		env["AWS_LAMBDA_LOG_STREAM_NAME"],
+	)
+}
+
+// TestFunctionEnv isn't parallel since it changes the process environment
+func TestFunctionEnv(t *testing.T) {
+	os.Setenv("AWS_REGION", "developer-region")
+	defer os.Unsetenv("AWS_REGION")
+
+	f := &config.Function{
+		Name:        "Echo",
+		Environment: map[string]string{"TABLE_NAME": "echo"},
+	}
+
+	t.Run("replaces runtime variables", func(t *testing.T) {
+		c := &config.Config{Path: "../ladle.confl", Region: "eu-west-1"}
+		env := functionEnv(c, f, time.Now())
+
+		assert.Contains(t, env, "TABLE_NAME=echo")
+		assert.Contains(t, env, "AWS_REGION=eu-west-1")
+		assert.NotContains(t, env, "AWS_REGION=developer-region")
+	})
+
+	t.Run("inherits runtime variables when opted in", func(t *testing.T) {
+		c := &config.Config{
+			Path:                      "../ladle.confl",
+			Region:                    "eu-west-1",
+			InheritRuntimeEnvironment: true,
+		}
+		env := functionEnv(c, f, time.Now())
+
+		assert.Contains(t, env, "AWS_REGION=developer-region")
+		assert.NotContains(t, env, "AWS_REGION=eu-west-1")
+		assert.Contains(t, env, "AWS_LAMBDA_FUNCTION_NAME=Echo")
+	})
+}
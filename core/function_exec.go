@@ -9,6 +9,7 @@ import (
 	"net/rpc"
 	"os"
 	"os/exec"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda/messages"
@@ -31,17 +32,53 @@ type FunctionExec struct {
 	cmd *exec.Cmd
 
 	// done is a channel to signal on stop
-	done chan<- string
+	done chan<- *FunctionExec
+
+	// stopped is closed once the function has been stopped
+	stopped chan struct{}
+
+	// stopOnce makes sure the function is only stopped once
+	stopOnce sync.Once
+
+	// startTime is when the function's process was started
+	startTime time.Time
+
+	// exited is closed once the function's process has exited
+	exited chan struct{}
+
+	// exitErr is the error returned when waiting on the process, if any
+	exitErr error
+
+	// crashed is true if the process exited without being stopped
+	crashed bool
+
+	// initDuration is how long the function took to start
+	initDuration time.Duration
+
+	// coldStart is true if the function was started for its next invocation
+	coldStart bool
+
+	// idleSince is when the function last became idle in its pool
+	idleSince time.Time
+
+	// requestID is the id of the invocation in progress, if any, so output
+	// can be tied to it
+	requestID string
+
+	// requestIDMtx guards requestID
+	requestIDMtx sync.Mutex
 }
 
 // StartFunction starts the given function and returns a FunctionExec.
 func StartFunction(
 	c *config.Config,
 	f *config.Function,
-	done chan<- string,
+	done chan<- *FunctionExec,
 ) (*FunctionExec, error) {
-	fnEx := &FunctionExec{Function: f}
+	fnEx := &FunctionExec{Config: c, Function: f}
 	fnEx.done = done
+	fnEx.stopped = make(chan struct{})
+	fnEx.exited = make(chan struct{})
 
 	port, portErr := freePort()
 	if portErr != nil {
@@ -52,50 +89,163 @@ func StartFunction(
 	executable := c.FunctionExecutable(f)
 	fnEx.cmd = exec.Command(executable)
 	fnEx.cmd.Env = append(
-		os.Environ(),
+		functionEnv(c, f, time.Now()),
 		fmt.Sprintf("_LAMBDA_SERVER_PORT=%d", fnEx.port),
 	)
 
+	setProcessGroup(fnEx.cmd)
+
 	read, write := io.Pipe()
 	fnEx.cmd.Stdout = write
 	fnEx.cmd.Stderr = write
 
+	fnEx.startTime = time.Now()
 	if runErr := fnEx.cmd.Start(); runErr != nil {
 		return nil, runErr
 	}
 
-	go readOutput(f.Name, read)
-	go fnEx.watchExecutable(executable)
+	go fnEx.readOutput(read)
+	go fnEx.wait(write)
+	if !c.Watch {
+		// when watching sources the runtime swaps in rebuilt functions itself
+		go fnEx.watchExecutable(executable)
+	}
 
 	// give it up to 10 seconds to actually start
 	pinged := false
-	start := time.Now()
-	for time.Now().Before(start.Add(10 * time.Second)) {
+	for time.Now().Before(fnEx.startTime.Add(10 * time.Second)) {
 		if fnEx.ping() {
 			pinged = true
 			break
 		}
-		time.Sleep(50 * time.Millisecond)
+
+		select {
+		case <-fnEx.exited:
+			fnEx.stopOnce.Do(func() { close(fnEx.stopped) })
+			return nil, fmt.Errorf(
+				"Fn %s: exited on startup (%s)",
+				f.Name,
+				exitDescription(fnEx.exitErr),
+			)
+		case <-time.After(10 * time.Millisecond):
+		}
 	}
 
 	if !pinged {
+		// stop without signaling done, since the function never started
+		fnEx.stopOnce.Do(func() {
+			close(fnEx.stopped)
+			killProcessGroup(fnEx.cmd)
+		})
 		return nil, fmt.Errorf("Fn %s: could not ping on startup", f.Name)
 	}
 
+	fnEx.initDuration = time.Now().Sub(fnEx.startTime)
 	log.Printf("Fn %s: Started on port %d\n", f.Name, fnEx.port)
 	return fnEx, nil
 }
 
 // StopFunction stops the function
 func StopFunction(fnEx *FunctionExec) error {
-	if fnEx.cmd != nil && fnEx.cmd.Process != nil {
-		killErr := fnEx.cmd.Process.Kill()
-		fnEx.done <- fnEx.Function.Name
-		return killErr
+	var killErr error
+
+	fnEx.stopOnce.Do(func() {
+		close(fnEx.stopped)
+
+		if fnEx.cmd != nil && fnEx.cmd.Process != nil {
+			select {
+			case <-fnEx.exited:
+			default:
+				killErr = killProcessGroup(fnEx.cmd)
+			}
+			fnEx.done <- fnEx
+		}
+	})
+
+	return killErr
+}
+
+// ShutdownFunction stops the function for good, without signaling done.
+// The function's process group is asked to exit and is killed if it hasn't
+// after the grace period. It returns true if the function had to be killed.
+func ShutdownFunction(fnEx *FunctionExec, grace time.Duration) bool {
+	killed := false
+
+	fnEx.stopOnce.Do(func() {
+		close(fnEx.stopped)
+
+		if fnEx.cmd == nil || fnEx.cmd.Process == nil {
+			return
+		}
+
+		if termErr := terminateProcessGroup(fnEx.cmd); termErr != nil {
+			log.Printf("Fn %s: %s\n", fnEx.Function.Name, termErr)
+		}
+
+		select {
+		case <-fnEx.exited:
+		case <-time.After(grace):
+			log.Printf(
+				"Fn %s: Still running after %s, killing\n",
+				fnEx.Function.Name,
+				grace,
+			)
+			killed = true
+			if killErr := killProcessGroup(fnEx.cmd); killErr != nil {
+				log.Printf("Fn %s: %s\n", fnEx.Function.Name, killErr)
+			}
+			<-fnEx.exited
+		}
+	})
+
+	return killed
+}
+
+// isStopped returns true once the function has been stopped, or has exited
+func (fnEx *FunctionExec) isStopped() bool {
+	select {
+	case <-fnEx.stopped:
+		return true
+	default:
+		return false
 	}
+}
+
+// Crashed returns true if the function's process exited without being
+// stopped. It's only meaningful once the function has signaled done.
+func (fnEx *FunctionExec) Crashed() bool {
+	return fnEx.crashed
+}
+
+// wait waits for the function's process to exit. If it exits without being
+// stopped it's marked as crashed and done is signaled so it's restarted.
+func (fnEx *FunctionExec) wait(output *io.PipeWriter) {
+	waitErr := fnEx.cmd.Wait()
+	output.Close()
+
+	fnEx.exitErr = waitErr
+	close(fnEx.exited)
+
+	fnEx.stopOnce.Do(func() {
+		fnEx.crashed = true
+		close(fnEx.stopped)
+
+		log.Printf(
+			"Fn %s: Exited unexpectedly (%s)\n",
+			fnEx.Function.Name,
+			exitDescription(waitErr),
+		)
+		fnEx.done <- fnEx
+	})
+}
 
-	// it wasn't running anyway
-	return nil
+// exitDescription describes how a process exited based on its wait error
+func exitDescription(waitErr error) string {
+	if waitErr == nil {
+		return "exit status 0"
+	}
+
+	return waitErr.Error()
 }
 
 // watchExecutable watches the executable for change and if it changes,
@@ -104,6 +254,12 @@ func (fnEx *FunctionExec) watchExecutable(handler string) {
 	mtime := time.Now()
 
 	for {
+		select {
+		case <-fnEx.stopped:
+			return
+		case <-time.After(1 * time.Second):
+		}
+
 		info, statErr := os.Stat(handler)
 		if statErr != nil {
 			panic(statErr)
@@ -120,22 +276,33 @@ func (fnEx *FunctionExec) watchExecutable(handler string) {
 			}
 			break
 		}
-
-		time.Sleep(1 * time.Second)
 	}
 }
 
-// readOutput reads output from the output buffer
-func readOutput(name string, out io.ReadCloser) {
+// readOutput reads output from the output buffer, tagging lines written
+// during an invocation with its request id
+func (fnEx *FunctionExec) readOutput(out io.ReadCloser) {
+	name := fnEx.Function.Name
+
 	r := bufio.NewReader(out)
 	for {
 		line, readErr := r.ReadString('\n')
 		if readErr != nil {
-			fmt.Println(readErr)
+			if readErr != io.EOF {
+				log.Printf("Fn %s: %s\n", name, readErr)
+			}
 			break
 		}
 
-		log.Printf("Fn %s: %s", name, line)
+		fnEx.requestIDMtx.Lock()
+		requestID := fnEx.requestID
+		fnEx.requestIDMtx.Unlock()
+
+		if requestID != "" {
+			log.Printf("Fn %s(%s): %s", name, requestID, line)
+		} else {
+			log.Printf("Fn %s: %s", name, line)
+		}
 	}
 
 	out.Close()
@@ -167,30 +334,151 @@ func (fnEx *FunctionExec) ping() bool {
 	return true
 }
 
-// Invoke invokes the given function with the given payload
+// setRequestID sets the id of the invocation in progress
+func (fnEx *FunctionExec) setRequestID(requestID string) {
+	fnEx.requestIDMtx.Lock()
+	fnEx.requestID = requestID
+	fnEx.requestIDMtx.Unlock()
+}
+
+// Invoke invokes the given function with the given payload. If the
+// invocation runs past the function's timeout the function is stopped, so
+// that it's restarted, and resp carries a timeout error. Like Lambda, START,
+// END and REPORT lines are logged around the invocation.
 func (fnEx *FunctionExec) Invoke(
 	req *messages.InvokeRequest,
 	resp *messages.InvokeResponse,
 ) error {
 	startTime := time.Now()
 
+	startKind := "warm start"
+	var initDuration time.Duration
+	if fnEx.coldStart {
+		initDuration = fnEx.initDuration
+		startKind = fmt.Sprintf("cold start, init %.3fms", milliseconds(initDuration))
+		fnEx.coldStart = false
+	}
+
+	timeout := functionTimeout(fnEx.Function)
+	deadline := startTime.Add(timeout)
+	prepareInvokeRequest(fnEx.Config, fnEx.Function, req, deadline)
+
+	fnEx.setRequestID(req.RequestId)
+	defer fnEx.setRequestID("")
+	fnEx.logLine(startLine(req.RequestId))
+
 	client, clientErr := fnEx.rpcClient()
 	if clientErr != nil {
-		fmt.Println(clientErr)
+		log.Printf("Fn %s: %s", fnEx.Function.Name, clientErr)
+		fnEx.logReport(
+			req.RequestId,
+			startTime,
+			maxMemoryUsed(fnEx.cmd.Process.Pid),
+			initDuration,
+		)
 		return clientErr
 	}
+	defer client.Close()
+
+	// the call gets its own response so that a late reply can't race with
+	// the timeout error
+	var callErr error
+	callResp := &messages.InvokeResponse{}
+	call := client.Go("Function.Invoke", req, callResp, make(chan *rpc.Call, 1))
+
+	// peak memory is sampled before the instance can be stopped, since its
+	// /proc entry goes with it
+	var maxMemory int
+
+	select {
+	case <-call.Done:
+		*resp = *callResp
+		callErr = call.Error
+		maxMemory = maxMemoryUsed(fnEx.cmd.Process.Pid)
+
+		if callErr != nil {
+			callErr = fnEx.describeCallErr(callErr)
+		} else if resp.Error != nil && resp.Error.ShouldExit {
+			// the runtime asks for a new instance after a panic
+			if stopErr := StopFunction(fnEx); stopErr != nil {
+				log.Printf("Fn %s: %s", fnEx.Function.Name, stopErr)
+			}
+		}
+	case <-time.After(time.Until(deadline)):
+		*resp = messages.InvokeResponse{
+			Error: &messages.InvokeResponse_Error{
+				Message: fmt.Sprintf(
+					"Task timed out after %.2f seconds",
+					timeout.Seconds(),
+				),
+			},
+		}
+
+		fnEx.logLine(timeoutLine(req.RequestId, time.Now(), timeout))
+		maxMemory = maxMemoryUsed(fnEx.cmd.Process.Pid)
+
+		if stopErr := StopFunction(fnEx); stopErr != nil {
+			log.Printf("Fn %s: %s", fnEx.Function.Name, stopErr)
+		}
+	}
+
+	duration := fnEx.logReport(req.RequestId, startTime, maxMemory, initDuration)
 
-	callErr := client.Call("Function.Invoke", req, resp)
 	log.Printf(
-		"Fn %s(%s): Invoke (%.3fms)",
+		"Fn %s(%s): Invoke (%.3fms, %s)",
 		fnEx.Function.Name,
 		req.RequestId,
-		float64(time.Now().Sub(startTime).Nanoseconds())/1000000,
+		milliseconds(duration),
+		startKind,
 	)
 
 	return callErr
 }
 
+// logReport logs the END and REPORT lines of an invocation that started at
+// startTime, returning its duration
+func (fnEx *FunctionExec) logReport(
+	requestID string,
+	startTime time.Time,
+	maxMemory int,
+	initDuration time.Duration,
+) time.Duration {
+	duration := time.Now().Sub(startTime)
+
+	fnEx.logLine(endLine(requestID))
+	fnEx.logLine(reportLine(
+		requestID,
+		duration,
+		functionMemorySize(fnEx.Function),
+		maxMemory,
+		initDuration,
+	))
+
+	return duration
+}
+
+// logLine logs a line as Lambda does, without a timestamp or prefix, so the
+// START, END and REPORT lines can be parsed like Lambda's
+func (fnEx *FunctionExec) logLine(line string) {
+	reportLog.Println(line)
+}
+
+// describeCallErr adds the exit status to an invocation error if the
+// function's process exited during the call
+func (fnEx *FunctionExec) describeCallErr(callErr error) error {
+	select {
+	case <-fnEx.exited:
+	case <-time.After(100 * time.Millisecond):
+		return callErr
+	}
+
+	return fmt.Errorf(
+		"Fn %s: exited during invocation (%s)",
+		fnEx.Function.Name,
+		exitDescription(fnEx.exitErr),
+	)
+}
+
 // freePort grabs a free port
 func freePort() (int, error) {
 	ln, listenErr := net.Listen("tcp", "localhost:0")
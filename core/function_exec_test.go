@@ -1,7 +1,11 @@
 package core
 
 import (
+	"bytes"
+	"os"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda/messages"
 	"github.com/nalanj/ladle/config"
@@ -18,7 +22,7 @@ func TestFunctionExecStart(t *testing.T) {
 	t.Run("returns an error if the executable doesn't exist", func(t *testing.T) {
 		t.Parallel()
 
-		done := make(chan string, 20)
+		done := make(chan *FunctionExec, 20)
 		f := &config.Function{Name: "NotHere", Package: echoPkg}
 		_, err := StartFunction(
 			&config.Config{Path: "../ladle.confl"},
@@ -31,7 +35,7 @@ func TestFunctionExecStart(t *testing.T) {
 	t.Run("starts the function if the handler is valid", func(t *testing.T) {
 		t.Parallel()
 
-		done := make(chan string, 20)
+		done := make(chan *FunctionExec, 20)
 		f := &config.Function{Name: "Echo", Package: echoPkg}
 		fnEx, err := StartFunction(
 			&config.Config{Path: "../ladle.confl"},
@@ -49,7 +53,7 @@ func TestFunctionExecStart(t *testing.T) {
 func TestFunctionInvoke(t *testing.T) {
 	t.Parallel()
 
-	done := make(chan string, 20)
+	done := make(chan *FunctionExec, 20)
 	f := &config.Function{Name: "Echo", Package: echoPkg}
 	fnEx, err := StartFunction(
 		&config.Config{Path: "../ladle.confl"},
@@ -67,3 +71,103 @@ func TestFunctionInvoke(t *testing.T) {
 	assert.NotNil(t, resp.Payload)
 	assert.Nil(t, StopFunction(fnEx))
 }
+
+func TestFunctionInvokeTimeout(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan *FunctionExec, 20)
+	f := &config.Function{
+		Name:    "Echo",
+		Package: echoPkg,
+		Timeout: time.Nanosecond,
+	}
+	fnEx, err := StartFunction(
+		&config.Config{Path: "../ladle.confl"},
+		f,
+		done,
+	)
+	assert.Nil(t, err)
+
+	req := &messages.InvokeRequest{Payload: []byte("{}")}
+	resp := &messages.InvokeResponse{}
+	invokeErr := fnEx.Invoke(req, resp)
+
+	assert.Nil(t, invokeErr)
+	assert.NotNil(t, resp.Error)
+	assert.Equal(t, "Task timed out after 0.00 seconds", resp.Error.Message)
+	assert.NotZero(t, req.Deadline.Seconds)
+	assert.Equal(t, fnEx, <-done)
+}
+
+// TestFunctionInvokeReportLines isn't parallel, so it's the only writer to
+// reportLog while it runs
+func TestFunctionInvokeReportLines(t *testing.T) {
+	var out bytes.Buffer
+	reportLog.SetOutput(&out)
+	defer reportLog.SetOutput(os.Stdout)
+
+	done := make(chan *FunctionExec, 20)
+	f := &config.Function{
+		Name:    "Echo",
+		Package: echoPkg,
+		Timeout: time.Nanosecond,
+	}
+	fnEx, err := StartFunction(
+		&config.Config{Path: "../ladle.confl"},
+		f,
+		done,
+	)
+	assert.Nil(t, err)
+
+	req := &messages.InvokeRequest{RequestId: "request-id", Payload: []byte("{}")}
+	assert.Nil(t, fnEx.Invoke(req, &messages.InvokeResponse{}))
+	<-done
+
+	lines := out.String()
+	assert.Regexp(t, `(?m)^START RequestId: request-id Version: \$LATEST$`, lines)
+	assert.Regexp(t, `(?m)^END RequestId: request-id$`, lines)
+	assert.Regexp(t, `(?m)^REPORT RequestId: request-id\t`, lines)
+
+	if runtime.GOOS == "linux" {
+		// memory is sampled before the timed out instance is stopped
+		assert.Regexp(t, `Max Memory Used: [1-9][0-9]* MB`, lines)
+	}
+}
+
+func TestFunctionExecCrash(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan *FunctionExec, 20)
+	f := &config.Function{Name: "Echo", Package: echoPkg}
+	fnEx, err := StartFunction(
+		&config.Config{Path: "../ladle.confl"},
+		f,
+		done,
+	)
+	assert.Nil(t, err)
+
+	// kill the process out from under the function to simulate a crash
+	assert.Nil(t, fnEx.cmd.Process.Kill())
+
+	assert.Equal(t, fnEx, <-done)
+	assert.True(t, fnEx.Crashed())
+	assert.Equal(t, "signal: killed", exitDescription(fnEx.exitErr))
+	assert.Nil(t, StopFunction(fnEx))
+}
+
+func TestShutdownFunction(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan *FunctionExec, 20)
+	f := &config.Function{Name: "Echo", Package: echoPkg}
+	fnEx, err := StartFunction(
+		&config.Config{Path: "../ladle.confl"},
+		f,
+		done,
+	)
+	assert.Nil(t, err)
+
+	assert.False(t, ShutdownFunction(fnEx, 5*time.Second))
+	assert.False(t, fnEx.Crashed())
+	assert.Len(t, done, 0)
+}
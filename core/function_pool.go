@@ -0,0 +1,429 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nalanj/ladle/config"
+	"github.com/nalanj/ladle/rpc"
+)
+
+// crashRecord tracks the consecutive crashes of a function
+type crashRecord struct {
+	// count is the number of consecutive crashes
+	count int
+
+	// err describes the last crash
+	err error
+
+	// at is when the last crash happened
+	at time.Time
+
+	// restartAt is when the function may start new instances again
+	restartAt time.Time
+
+	// gaveUp is true once the function hit the crash loop limit
+	gaveUp bool
+}
+
+// functionPool is the set of running instances of a function. Like Lambda,
+// each instance handles one invocation at a time, new instances are started
+// on demand up to the function's reserved concurrency, and instances that
+// sit idle past the keep warm period are stopped.
+type functionPool struct {
+	// conf is the configuration being run against
+	conf *config.Config
+
+	// function is the function the instances are running
+	function *config.Function
+
+	// fnDone is passed to instances to signal when they stop
+	fnDone chan<- *FunctionExec
+
+	// mtx guards everything below
+	mtx sync.Mutex
+
+	// instances maps every instance in the pool to the generation it was
+	// started in
+	instances map[*FunctionExec]int
+
+	// idle are the instances waiting for an invocation
+	idle []*FunctionExec
+
+	// starting is the number of instances being started
+	starting int
+
+	// generation is bumped when a rebuild replaces the pool's instances
+	generation int
+
+	// crash is the function's crash record, if it's been crashing
+	crash *crashRecord
+
+	// awaitingChange is true while a crash looping function's executable is
+	// being watched for a change
+	awaitingChange bool
+
+	// closed is true once the pool is shut down
+	closed bool
+
+	// shutdown is closed when the pool is shut down
+	shutdown chan struct{}
+}
+
+// newFunctionPool returns a new, empty, pool for the given function
+func newFunctionPool(
+	conf *config.Config,
+	f *config.Function,
+	fnDone chan<- *FunctionExec,
+) *functionPool {
+	return &functionPool{
+		conf:      conf,
+		function:  f,
+		fnDone:    fnDone,
+		instances: make(map[*FunctionExec]int),
+		shutdown:  make(chan struct{}),
+	}
+}
+
+// concurrency returns the maximum number of instances in the pool
+func (p *functionPool) concurrency() int {
+	if p.function.ReservedConcurrency <= 0 {
+		return config.DefaultReservedConcurrency
+	}
+
+	return p.function.ReservedConcurrency
+}
+
+// acquire returns an idle instance for an invocation, starting a new one if
+// none are idle. The instance must be given back with release.
+func (p *functionPool) acquire() (*FunctionExec, error) {
+	p.mtx.Lock()
+
+	if p.closed {
+		p.mtx.Unlock()
+		return nil, fmt.Errorf("Function %s is shutting down", p.function.Name)
+	}
+
+	for len(p.idle) > 0 {
+		fnEx := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+
+		if fnEx.isStopped() {
+			// the instance stopped while idle and its done hasn't been
+			// handled yet, so handle it here instead
+			delete(p.instances, fnEx)
+			if fnEx.Crashed() {
+				p.crashed(fnEx.startTime, fnEx.exitErr)
+			}
+			continue
+		}
+
+		p.mtx.Unlock()
+		return fnEx, nil
+	}
+
+	if p.crash != nil && p.crash.gaveUp {
+		defer p.mtx.Unlock()
+		return nil, fmt.Errorf(
+			"Function %s is in a crash loop after %d crashes (%s)",
+			p.function.Name,
+			p.crash.count,
+			p.crash.err,
+		)
+	}
+
+	if p.crash != nil && time.Now().Before(p.crash.restartAt) {
+		defer p.mtx.Unlock()
+		return nil, fmt.Errorf(
+			"Function %s crashed (%s) and is restarting",
+			p.function.Name,
+			p.crash.err,
+		)
+	}
+
+	if len(p.instances)+p.starting >= p.concurrency() {
+		p.mtx.Unlock()
+		return nil, &rpc.ThrottleError{Name: p.function.Name}
+	}
+
+	fnEx, startErr := p.start()
+	if startErr != nil {
+		return nil, startErr
+	}
+
+	// the instance was started for this invocation
+	fnEx.coldStart = true
+	return fnEx, nil
+}
+
+// release gives an instance back to the pool after an invocation
+func (p *functionPool) release(fnEx *FunctionExec) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	generation, ok := p.instances[fnEx]
+	if !ok || fnEx.isStopped() {
+		// the instance stopped during the invocation and signaled done
+		return
+	}
+
+	if generation != p.generation {
+		// a rebuild replaced the instance while it was busy
+		p.retire(fnEx)
+		return
+	}
+
+	fnEx.idleSince = time.Now()
+	p.idle = append(p.idle, fnEx)
+}
+
+// reap stops the instances that have been idle for longer than the keep
+// warm period
+func (p *functionPool) reap(now time.Time) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	keepWarm := p.conf.KeepWarm
+	if keepWarm <= 0 {
+		keepWarm = config.DefaultKeepWarm
+	}
+
+	for _, fnEx := range append([]*FunctionExec{}, p.idle...) {
+		if now.Sub(fnEx.idleSince) > keepWarm {
+			log.Printf(
+				"Core: Stopping Fn %s after %s idle\n",
+				p.function.Name,
+				keepWarm,
+			)
+			p.retire(fnEx)
+		}
+	}
+}
+
+// warm starts an instance and adds it to the idle instances
+func (p *functionPool) warm() {
+	p.mtx.Lock()
+	if p.closed || len(p.instances)+p.starting >= p.concurrency() {
+		p.mtx.Unlock()
+		return
+	}
+
+	fnEx, startErr := p.start()
+	if startErr != nil {
+		log.Printf("Core: %s\n", startErr)
+		return
+	}
+
+	p.release(fnEx)
+}
+
+// start starts a new instance, counting it as busy. p.mtx must be held, and
+// is released.
+func (p *functionPool) start() (*FunctionExec, error) {
+	p.starting++
+	generation := p.generation
+	p.mtx.Unlock()
+
+	startTime := time.Now()
+	fnEx, startErr := StartFunction(p.conf, p.function, p.fnDone)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.starting--
+
+	if startErr != nil {
+		p.crashed(startTime, startErr)
+		return nil, startErr
+	}
+
+	if p.closed {
+		go ShutdownFunction(fnEx, shutdownGrace)
+		return nil, fmt.Errorf("Function %s is shutting down", p.function.Name)
+	}
+
+	// a start long after the last crash means the function recovered
+	if p.crash != nil && startTime.Sub(p.crash.at) > crashResetAfter {
+		p.crash = nil
+	}
+
+	p.instances[fnEx] = generation
+	return fnEx, nil
+}
+
+// replace makes fnEx, a freshly built instance, the only idle instance of
+// the function. Other idle instances are stopped right away, and busy ones
+// when they're released.
+func (p *functionPool) replace(fnEx *FunctionExec) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.closed {
+		go ShutdownFunction(fnEx, shutdownGrace)
+		return
+	}
+
+	for _, idleEx := range p.idle {
+		p.retire(idleEx)
+	}
+
+	p.generation++
+	p.crash = nil
+	p.instances[fnEx] = p.generation
+	fnEx.idleSince = time.Now()
+	p.idle = []*FunctionExec{fnEx}
+}
+
+// retire removes an instance from the pool and stops it. p.mtx must be held.
+func (p *functionPool) retire(fnEx *FunctionExec) {
+	p.remove(fnEx)
+
+	go func() {
+		if stopErr := StopFunction(fnEx); stopErr != nil {
+			log.Printf("Core: %s\n", stopErr)
+		}
+	}()
+}
+
+// remove removes an instance from the pool, returning false if it wasn't in
+// the pool. p.mtx must be held.
+func (p *functionPool) remove(fnEx *FunctionExec) bool {
+	if _, ok := p.instances[fnEx]; !ok {
+		return false
+	}
+
+	delete(p.instances, fnEx)
+	for i, idleEx := range p.idle {
+		if idleEx == fnEx {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// stopped handles an instance that signaled done. Crashed instances are
+// counted towards the crash loop limit. Like any other stopped instance, they
+// aren't replaced until the next invocation needs one. Other stops leave the
+// crash record alone, as they say nothing about whether the function
+// recovered.
+func (p *functionPool) stopped(fnEx *FunctionExec) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if !p.remove(fnEx) {
+		// the instance was already retired, so there's nothing to do
+		return
+	}
+
+	if fnEx.Crashed() {
+		p.crashed(fnEx.startTime, fnEx.exitErr)
+	}
+}
+
+// crashed records a crash of an instance started at startTime, and holds off
+// on starting new instances for a backoff period, or gives up on the
+// function if it's crashing in a loop. p.mtx must be held.
+func (p *functionPool) crashed(startTime time.Time, crashErr error) {
+	now := time.Now()
+
+	if p.crash == nil || now.Sub(startTime) > crashResetAfter {
+		p.crash = &crashRecord{}
+	}
+
+	p.crash.count++
+	p.crash.err = fmt.Errorf("%s", exitDescription(crashErr))
+	p.crash.at = now
+
+	if p.crash.count >= crashLoopLimit {
+		p.crash.gaveUp = true
+		log.Printf(
+			"Core: Fn %s crashed %d times in a row (%s), not restarting until it changes\n",
+			p.function.Name,
+			p.crash.count,
+			p.crash.err,
+		)
+
+		if !p.awaitingChange && !p.closed {
+			p.awaitingChange = true
+			go p.awaitChange(now)
+		}
+		return
+	}
+
+	delay := crashBackoff << uint(p.crash.count-1)
+	if delay > maxCrashBackoff {
+		delay = maxCrashBackoff
+	}
+	p.crash.restartAt = now.Add(delay)
+
+	log.Printf(
+		"Core: Fn %s crashed, starting new instances in %s (crash %d of %d)\n",
+		p.function.Name,
+		delay,
+		p.crash.count,
+		crashLoopLimit,
+	)
+}
+
+// awaitChange waits for the executable of a function that was given up on
+// for crashing in a loop to change, and then lets it start again. It stops
+// early if the pool is shut down or a rebuild already cleared the crash loop.
+func (p *functionPool) awaitChange(since time.Time) {
+	for {
+		select {
+		case <-p.shutdown:
+			p.mtx.Lock()
+			p.awaitingChange = false
+			p.mtx.Unlock()
+			return
+		case <-time.After(1 * time.Second):
+		}
+
+		info, statErr := os.Stat(p.conf.FunctionExecutable(p.function))
+		changed := statErr == nil && info.ModTime().After(since)
+
+		p.mtx.Lock()
+		if p.crash == nil || !p.crash.gaveUp {
+			// a rebuild already cleared the crash loop
+			p.awaitingChange = false
+			p.mtx.Unlock()
+			return
+		} else if changed {
+			log.Printf(
+				"Core: Fn %s changed, starting again after crash loop\n",
+				p.function.Name,
+			)
+			p.crash = nil
+			p.awaitingChange = false
+			p.mtx.Unlock()
+			return
+		}
+		p.mtx.Unlock()
+	}
+}
+
+// close shuts down the pool and returns all of its instances, which the
+// caller is responsible for stopping
+func (p *functionPool) close() []*FunctionExec {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if !p.closed {
+		p.closed = true
+		close(p.shutdown)
+	}
+
+	instances := make([]*FunctionExec, 0, len(p.instances))
+	for fnEx := range p.instances {
+		instances = append(instances, fnEx)
+	}
+
+	p.instances = make(map[*FunctionExec]int)
+	p.idle = nil
+
+	return instances
+}
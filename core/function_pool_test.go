@@ -0,0 +1,143 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nalanj/ladle/config"
+	"github.com/nalanj/ladle/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctionPool(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan *FunctionExec, 20)
+	f := &config.Function{
+		Name:                "Echo",
+		Package:             echoPkg,
+		ReservedConcurrency: 1,
+	}
+	pool := newFunctionPool(&config.Config{Path: "../ladle.confl"}, f, done)
+
+	// a start soon after a crash doesn't reset the crash count
+	pool.crash = &crashRecord{count: 2, at: time.Now()}
+
+	fnEx, acquireErr := pool.acquire()
+	assert.Nil(t, acquireErr)
+	assert.NotNil(t, fnEx)
+	assert.True(t, fnEx.coldStart)
+	assert.NotZero(t, fnEx.initDuration)
+	assert.Equal(t, 2, pool.crash.count)
+	fnEx.coldStart = false
+
+	// the only instance is busy, so the next invocation is throttled
+	_, throttleErr := pool.acquire()
+	assert.Equal(t, &rpc.ThrottleError{Name: "Echo"}, throttleErr)
+
+	// once released, the instance is reused
+	pool.release(fnEx)
+	reusedEx, reuseErr := pool.acquire()
+	assert.Nil(t, reuseErr)
+	assert.Equal(t, fnEx, reusedEx)
+	assert.False(t, reusedEx.coldStart)
+	pool.release(reusedEx)
+
+	// instances idle past the keep warm period are stopped
+	pool.reap(time.Now().Add(config.DefaultKeepWarm + time.Second))
+	assert.Equal(t, fnEx, <-done)
+	assert.Len(t, pool.idle, 0)
+	pool.stopped(fnEx)
+	assert.NotNil(t, pool.crash)
+
+	assert.Len(t, pool.close(), 0)
+
+	_, closedErr := pool.acquire()
+	assert.NotNil(t, closedErr)
+}
+
+func TestFunctionPoolCrashed(t *testing.T) {
+	t.Parallel()
+
+	f := &config.Function{Name: "Echo"}
+	pool := newFunctionPool(&config.Config{}, f, nil)
+
+	pool.mtx.Lock()
+	defer pool.mtx.Unlock()
+
+	pool.crashed(time.Now(), errors.New("exit status 2"))
+	pool.crashed(time.Now(), errors.New("exit status 2"))
+	assert.Equal(t, 2, pool.crash.count)
+	assert.EqualError(t, pool.crash.err, "exit status 2")
+	assert.True(t, pool.crash.restartAt.After(time.Now()))
+
+	// an instance that stayed up for a while resets the count
+	pool.crashed(
+		time.Now().Add(-2*crashResetAfter),
+		errors.New("exit status 1"),
+	)
+	assert.Equal(t, 1, pool.crash.count)
+	assert.EqualError(t, pool.crash.err, "exit status 1")
+}
+
+func TestFunctionPoolCrashLoop(t *testing.T) {
+	t.Parallel()
+
+	f := &config.Function{Name: "Echo"}
+	pool := newFunctionPool(&config.Config{}, f, nil)
+
+	pool.mtx.Lock()
+	for i := 0; i < crashLoopLimit+2; i++ {
+		pool.crashed(time.Now(), errors.New("exit status 2"))
+	}
+	assert.True(t, pool.crash.gaveUp)
+	assert.True(t, pool.awaitingChange)
+
+	// stopping an instance that didn't crash keeps the crash loop
+	fnEx := &FunctionExec{}
+	pool.instances[fnEx] = pool.generation
+	pool.mtx.Unlock()
+
+	pool.stopped(fnEx)
+	_, acquireErr := pool.acquire()
+	assert.Contains(t, acquireErr.Error(), "crash loop")
+
+	// closing the pool stops watching for a change
+	pool.close()
+	awaiting := true
+	for deadline := time.Now().Add(time.Second); awaiting && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+
+		pool.mtx.Lock()
+		awaiting = pool.awaitingChange
+		pool.mtx.Unlock()
+	}
+	assert.False(t, awaiting)
+}
+
+func TestFunctionPoolStoppedIdle(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan *FunctionExec, 20)
+	f := &config.Function{
+		Name:                "Echo",
+		Package:             echoPkg,
+		ReservedConcurrency: 1,
+	}
+	pool := newFunctionPool(&config.Config{Path: "../ladle.confl"}, f, done)
+
+	// an idle instance that stopped before its done was handled is skipped
+	stoppedEx := &FunctionExec{stopped: make(chan struct{})}
+	close(stoppedEx.stopped)
+	pool.instances[stoppedEx] = pool.generation
+	pool.idle = []*FunctionExec{stoppedEx}
+
+	fnEx, acquireErr := pool.acquire()
+	assert.Nil(t, acquireErr)
+	assert.NotEqual(t, stoppedEx, fnEx)
+	assert.True(t, fnEx.coldStart)
+	assert.NotContains(t, pool.instances, stoppedEx)
+
+	assert.Nil(t, StopFunction(fnEx))
+}
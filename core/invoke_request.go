@@ -0,0 +1,74 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/gofrs/uuid"
+	"github.com/nalanj/ladle/config"
+)
+
+// prepareInvokeRequest fills in the Lambda invocation context for the given
+// function on req. Callers only need to provide the payload, but anything
+// they've already set, like a request id or client context, is kept.
+func prepareInvokeRequest(
+	c *config.Config,
+	f *config.Function,
+	req *messages.InvokeRequest,
+	deadline time.Time,
+) {
+	if req.RequestId == "" {
+		req.RequestId = uuid.Must(uuid.NewV4()).String()
+	}
+
+	if req.XAmznTraceId == "" {
+		req.XAmznTraceId = traceID(time.Now())
+	}
+
+	req.InvokedFunctionArn = c.FunctionArn(f)
+	req.Deadline = messages.InvokeRequest_Timestamp{
+		Seconds: deadline.Unix(),
+		Nanos:   int64(deadline.Nanosecond()),
+	}
+}
+
+// functionTimeout returns the invocation timeout for the given function
+func functionTimeout(f *config.Function) time.Duration {
+	if f.Timeout <= 0 {
+		return config.DefaultTimeout
+	}
+
+	return f.Timeout
+}
+
+// functionMemorySize returns the memory size in MB of the given function
+func functionMemorySize(f *config.Function) int {
+	if f.MemorySize <= 0 {
+		return config.DefaultMemorySize
+	}
+
+	return f.MemorySize
+}
+
+// traceID generates an X-Ray trace header for a new, unsampled trace
+func traceID(now time.Time) string {
+	return fmt.Sprintf(
+		"Root=1-%08x-%s;Parent=%s;Sampled=0",
+		now.Unix(),
+		randomHex(12),
+		randomHex(8),
+	)
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, readErr := rand.Read(buf); readErr != nil {
+		panic(readErr)
+	}
+
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/nalanj/ladle/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareInvokeRequest(t *testing.T) {
+	t.Parallel()
+
+	c := &config.Config{Region: "eu-west-1", AccountID: "000000000000"}
+	f := &config.Function{Name: "Echo"}
+	deadline := time.Unix(1500000000, 500)
+
+	t.Run("fills in the invocation context", func(t *testing.T) {
+		t.Parallel()
+
+		req := &messages.InvokeRequest{Payload: []byte("{}")}
+		prepareInvokeRequest(c, f, req, deadline)
+
+		assert.NotEmpty(t, req.RequestId)
+		assert.Equal(
+			t,
+			"arn:aws:lambda:eu-west-1:000000000000:function:Echo",
+			req.InvokedFunctionArn,
+		)
+		assert.Equal(
+			t,
+			messages.InvokeRequest_Timestamp{Seconds: 1500000000, Nanos: 500},
+			req.Deadline,
+		)
+		assert.Regexp(
+			t,
+			regexp.MustCompile(
+				`^Root=1-[0-9a-f]{8}-[0-9a-f]{24};Parent=[0-9a-f]{16};Sampled=0$`,
+			),
+			req.XAmznTraceId,
+		)
+	})
+
+	t.Run("keeps caller provided values", func(t *testing.T) {
+		t.Parallel()
+
+		req := &messages.InvokeRequest{
+			RequestId:     "request-id",
+			XAmznTraceId:  "Root=1-5759e988-bd862e3fe1be46a994272793",
+			ClientContext: []byte(`{"custom":{"key":"value"}}`),
+		}
+		prepareInvokeRequest(c, f, req, deadline)
+
+		assert.Equal(t, "request-id", req.RequestId)
+		assert.Equal(
+			t,
+			"Root=1-5759e988-bd862e3fe1be46a994272793",
+			req.XAmznTraceId,
+		)
+		assert.Equal(t, []byte(`{"custom":{"key":"value"}}`), req.ClientContext)
+	})
+}
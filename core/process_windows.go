@@ -0,0 +1,19 @@
+package core
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing on windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup kills the command's process, since windows has no
+// way to ask it to exit
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// killProcessGroup kills the command's process
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
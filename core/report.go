@@ -0,0 +1,93 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportLog logs the START, END and REPORT lines of invocations bare, as
+// Lambda writes them, unlike ladle's own timestamped messages
+var reportLog = log.New(os.Stdout, "", 0)
+
+// startLine returns the START line Lambda logs before an invocation
+func startLine(requestID string) string {
+	return fmt.Sprintf("START RequestId: %s Version: %s", requestID, functionVersion)
+}
+
+// endLine returns the END line Lambda logs after an invocation
+func endLine(requestID string) string {
+	return fmt.Sprintf("END RequestId: %s", requestID)
+}
+
+// timeoutLine returns the line Lambda logs when an invocation times out
+func timeoutLine(requestID string, now time.Time, timeout time.Duration) string {
+	return fmt.Sprintf(
+		"%s %s Task timed out after %.2f seconds",
+		now.UTC().Format("2006-01-02T15:04:05.000Z"),
+		requestID,
+		timeout.Seconds(),
+	)
+}
+
+// reportLine returns the REPORT line Lambda logs after an invocation. The
+// init duration is only included for cold starts, when it's non-zero.
+func reportLine(
+	requestID string,
+	duration time.Duration,
+	memorySize int,
+	maxMemoryUsed int,
+	initDuration time.Duration,
+) string {
+	line := fmt.Sprintf(
+		"REPORT RequestId: %s\tDuration: %.2f ms\tBilled Duration: %d ms\tMemory Size: %d MB\tMax Memory Used: %d MB\t",
+		requestID,
+		milliseconds(duration),
+		int(math.Ceil(milliseconds(duration))),
+		memorySize,
+		maxMemoryUsed,
+	)
+
+	if initDuration > 0 {
+		line += fmt.Sprintf("Init Duration: %.2f ms\t", milliseconds(initDuration))
+	}
+
+	return line
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Nanoseconds()) / 1000000
+}
+
+// maxMemoryUsed returns the peak resident set size in MB of the process with
+// the given pid, read from /proc. It returns 0 if /proc isn't available.
+func maxMemoryUsed(pid int) int {
+	status, openErr := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if openErr != nil {
+		return 0
+	}
+	defer status.Close()
+
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "VmHWM:" {
+			continue
+		}
+
+		kb, convErr := strconv.Atoi(fields[1])
+		if convErr != nil {
+			return 0
+		}
+
+		return int(math.Ceil(float64(kb) / 1024))
+	}
+
+	return 0
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportLine(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports a warm start", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(
+			t,
+			"REPORT RequestId: request-id\tDuration: 2.26 ms\tBilled Duration: 3 ms\tMemory Size: 128 MB\tMax Memory Used: 60 MB\t",
+			reportLine("request-id", 2260*time.Microsecond, 128, 60, 0),
+		)
+	})
+
+	t.Run("reports the init duration of a cold start", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(
+			t,
+			"REPORT RequestId: request-id\tDuration: 100.00 ms\tBilled Duration: 100 ms\tMemory Size: 512 MB\tMax Memory Used: 60 MB\tInit Duration: 120.74 ms\t",
+			reportLine(
+				"request-id",
+				100*time.Millisecond,
+				512,
+				60,
+				120740*time.Microsecond,
+			),
+		)
+	})
+}
+
+func TestStartEndLines(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(
+		t,
+		"START RequestId: request-id Version: $LATEST",
+		startLine("request-id"),
+	)
+	assert.Equal(t, "END RequestId: request-id", endLine("request-id"))
+	assert.Equal(
+		t,
+		"2019-05-01T10:20:30.400Z request-id Task timed out after 3.00 seconds",
+		timeoutLine(
+			"request-id",
+			time.Date(2019, 5, 1, 10, 20, 30, 400000000, time.UTC),
+			3*time.Second,
+		),
+	)
+}
+
+func TestMaxMemoryUsed(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("/proc is only available on linux")
+	}
+
+	assert.True(t, maxMemoryUsed(os.Getpid()) > 0)
+	assert.Equal(t, 0, maxMemoryUsed(-1))
+}
@@ -1,9 +1,15 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda/messages"
 	"github.com/nalanj/ladle/config"
@@ -11,70 +17,150 @@ import (
 	"github.com/nalanj/ladle/rpc"
 )
 
-var runningFunctions map[string]*FunctionExec
-var runningFunctionsMtx sync.Mutex
+const (
+	// crashBackoff is the delay before restarting a function after its first
+	// crash. It doubles with each consecutive crash.
+	crashBackoff = 100 * time.Millisecond
 
-// StartRuntime starts the runtime
+	// maxCrashBackoff is the longest delay before restarting a function
+	maxCrashBackoff = 10 * time.Second
+
+	// crashLoopLimit is the number of consecutive crashes after which a
+	// function is no longer restarted
+	crashLoopLimit = 5
+
+	// crashResetAfter is how long a function has to stay up for its crashes
+	// to no longer count as consecutive
+	crashResetAfter = 30 * time.Second
+
+	// shutdownGrace is how long functions have to exit on shutdown before
+	// they're killed
+	shutdownGrace = 5 * time.Second
+)
+
+// functionPools maps function names to their pools of running instances. It
+// isn't changed after the runtime starts.
+var functionPools map[string]*functionPool
+
+// StartRuntime starts the runtime. It runs until ladle receives SIGINT or
+// SIGTERM, and then shuts down the listeners and functions gracefully.
 func StartRuntime(conf *config.Config) error {
-	runningFunctionsMtx.Lock()
-	runningFunctions = make(map[string]*FunctionExec)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	functionPools = make(map[string]*functionPool)
 
-	fnDone := make(chan string, 20)
+	fnDone := make(chan *FunctionExec, 20)
 	for _, f := range conf.Functions {
-		fnEx, err := StartFunction(conf, f, fnDone)
-		if err != nil {
-			panic(err)
+		rpc.Register(f.Name)
+		functionPools[f.Name] = newFunctionPool(conf, f, fnDone)
+	}
+
+	if conf.EagerStart {
+		var warming sync.WaitGroup
+		for _, pool := range functionPools {
+			warming.Add(1)
+			go func(pool *functionPool) {
+				defer warming.Done()
+				pool.warm()
+			}(pool)
 		}
+		warming.Wait()
+	}
 
-		rpc.Register(f.Name)
-		runningFunctions[f.Name] = fnEx
+	ctx, cancel := context.WithCancel(context.Background())
+	var listeners sync.WaitGroup
+	listenErrs := make(chan error, 2)
+
+	listen := func(listener func() error) {
+		listeners.Add(1)
+		go func() {
+			defer listeners.Done()
+			if err := listener(); err != nil {
+				listenErrs <- err
+			}
+		}()
+	}
+	listen(func() error { return rpc.Listen(ctx, conf, globalInvoker) })
+	listen(func() error { return gw.Listener(ctx, conf, globalInvoker) })
+
+	if conf.Watch {
+		go watchSources(conf, fnDone)
 	}
-	runningFunctionsMtx.Unlock()
 
-	go rpc.Listen(conf, globalInvoker)
-	go gw.Listener(conf, globalInvoker)
+	reap := time.NewTicker(1 * time.Second)
+	defer reap.Stop()
 
 	for {
 		select {
-		case restart := <-fnDone:
-			log.Printf("Core: Restarting Fn %s\n", restart)
-
-			runningFunctionsMtx.Lock()
-			oldEx, ok := runningFunctions[restart]
-			if !ok {
-				log.Printf(
-					"Core: Attempted restart on inactive function %s\n",
-					restart,
-				)
-				continue
+		case now := <-reap.C:
+			for _, pool := range functionPools {
+				pool.reap(now)
 			}
+		case sig := <-signals:
+			log.Printf("Core: Received %s, shutting down\n", sig)
+			cancel()
+			listeners.Wait()
+			shutdownFunctions()
+			return nil
+		case listenErr := <-listenErrs:
+			cancel()
+			listeners.Wait()
+			shutdownFunctions()
+			return listenErr
+		case fnEx := <-fnDone:
+			functionPools[fnEx.Function.Name].stopped(fnEx)
+		}
+	}
+}
 
-			delete(runningFunctions, restart)
+// shutdownFunctions stops every running function instance, giving each the
+// shutdown grace period to exit, and logs a summary
+func shutdownFunctions() {
+	stopping := []*FunctionExec{}
+	for _, pool := range functionPools {
+		stopping = append(stopping, pool.close()...)
+	}
 
-			fnEx, err := StartFunction(conf, oldEx.Function, fnDone)
-			if err != nil {
-				panic(err)
+	var wg sync.WaitGroup
+	var killed int32
+	for _, fnEx := range stopping {
+		wg.Add(1)
+		go func(fnEx *FunctionExec) {
+			defer wg.Done()
+			if ShutdownFunction(fnEx, shutdownGrace) {
+				atomic.AddInt32(&killed, 1)
 			}
-
-			runningFunctions[fnEx.Function.Name] = fnEx
-			runningFunctionsMtx.Unlock()
-		}
+		}(fnEx)
 	}
+	wg.Wait()
+
+	log.Printf(
+		"Core: Shutdown complete, stopped %d function instances (%d killed after %s)\n",
+		len(stopping),
+		killed,
+		shutdownGrace,
+	)
 }
 
-// globalInvoker is an invoker based on the runtime function config
+// globalInvoker is an invoker based on the runtime function config. Each
+// invocation gets an instance of its own.
 func globalInvoker(
 	name string,
 	req *messages.InvokeRequest,
 	resp *messages.InvokeResponse,
 ) error {
-	runningFunctionsMtx.Lock()
-	fnEx, ok := runningFunctions[name]
-	runningFunctionsMtx.Unlock()
-
+	pool, ok := functionPools[name]
 	if !ok {
 		return fmt.Errorf("Function %s not running", name)
 	}
 
+	fnEx, acquireErr := pool.acquire()
+	if acquireErr != nil {
+		return acquireErr
+	}
+	defer pool.release(fnEx)
+
 	return fnEx.Invoke(req, resp)
 }
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/nalanj/ladle/config"
+)
+
+const (
+	// watchDebounce is how long sources have to go unchanged before affected
+	// functions are rebuilt
+	watchDebounce = 300 * time.Millisecond
+)
+
+// listedPackage is the subset of go list's json output used to find the
+// source directories of a function
+type listedPackage struct {
+	Dir      string
+	Standard bool
+	Module   *struct {
+		Main    bool
+		Replace *struct{}
+	}
+}
+
+// watchSources watches the go sources of every function and rebuilds
+// functions whose sources change, swapping in the rebuilt function only if
+// the build succeeds
+func watchSources(conf *config.Config, fnDone chan<- *FunctionExec) {
+	watcher, watcherErr := newDirWatcher()
+	if watcherErr != nil {
+		log.Printf("Watch: %s\n", watcherErr)
+		return
+	}
+	defer watcher.Close()
+
+	// dirFunctions maps source directories to the functions depending on them
+	dirFunctions := make(map[string]map[string]*config.Function)
+	for _, f := range conf.Functions {
+		watchFunction(watcher, dirFunctions, f)
+	}
+
+	pending := make(map[string]*config.Function)
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case changed, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Ext(changed) != ".go" {
+				continue
+			}
+
+			for name, f := range dirFunctions[filepath.Dir(changed)] {
+				pending[name] = f
+			}
+
+			if len(pending) > 0 {
+				debounce = time.After(watchDebounce)
+			}
+		case <-debounce:
+			for _, f := range pending {
+				rebuildFunction(conf, f, fnDone)
+
+				// the rebuild may have changed the function's imports
+				watchFunction(watcher, dirFunctions, f)
+			}
+
+			pending = make(map[string]*config.Function)
+			debounce = nil
+		}
+	}
+}
+
+// watchFunction resolves the source directories of a function and watches
+// them
+func watchFunction(
+	watcher *dirWatcher,
+	dirFunctions map[string]map[string]*config.Function,
+	f *config.Function,
+) {
+	dirs, dirsErr := packageDirs(f.Package)
+	if dirsErr != nil {
+		log.Printf("Watch: Fn %s: %s\n", f.Name, dirsErr)
+		return
+	}
+
+	for _, functions := range dirFunctions {
+		delete(functions, f.Name)
+	}
+
+	for _, dir := range dirs {
+		if _, ok := dirFunctions[dir]; !ok {
+			if addErr := watcher.Add(dir); addErr != nil {
+				log.Printf("Watch: Fn %s: %s\n", f.Name, addErr)
+				continue
+			}
+
+			dirFunctions[dir] = make(map[string]*config.Function)
+		}
+
+		dirFunctions[dir][f.Name] = f
+	}
+}
+
+// packageDirs returns the source directories of a package and all of its
+// dependencies, skipping the standard library and downloaded modules since
+// they don't change
+func packageDirs(pkg string) ([]string, error) {
+	out, listErr := exec.Command("go", "list", "-deps", "-json", pkg).Output()
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	dirs := []string{}
+
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var listed listedPackage
+		decodeErr := dec.Decode(&listed)
+		if decodeErr == io.EOF {
+			break
+		} else if decodeErr != nil {
+			return nil, decodeErr
+		}
+
+		if listed.Standard || listed.Dir == "" {
+			continue
+		}
+
+		if listed.Module != nil &&
+			!listed.Module.Main &&
+			listed.Module.Replace == nil {
+			continue
+		}
+
+		dirs = append(dirs, listed.Dir)
+	}
+
+	return dirs, nil
+}
+
+// rebuildFunction builds the given function and, if the build succeeds,
+// starts the new build and swaps it in for the running instance. On failure
+// the running instance keeps serving.
+func rebuildFunction(
+	conf *config.Config,
+	f *config.Function,
+	fnDone chan<- *FunctionExec,
+) {
+	if buildErr := BuildFunction(conf, f); buildErr != nil {
+		log.Printf(
+			"Watch: Fn %s failed to build, keeping the running instance (%s)\n",
+			f.Name,
+			buildErr,
+		)
+		return
+	}
+
+	fnEx, startErr := StartFunction(conf, f, fnDone)
+	if startErr != nil {
+		log.Printf(
+			"Watch: Fn %s failed to start, keeping the running instance (%s)\n",
+			f.Name,
+			startErr,
+		)
+		return
+	}
+
+	functionPools[f.Name].replace(fnEx)
+	log.Printf("Watch: Fn %s rebuilt\n", f.Name)
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageDirs(t *testing.T) {
+	t.Parallel()
+
+	echoDir, absErr := filepath.Abs("../lambdas/echo")
+	assert.Nil(t, absErr)
+
+	dirs, dirsErr := packageDirs(echoPkg)
+	assert.Nil(t, dirsErr)
+
+	// downloaded modules, like aws-lambda-go, and the standard library are
+	// skipped
+	assert.Equal(t, []string{echoDir}, dirs)
+}
+
+func TestDirWatcher(t *testing.T) {
+	t.Parallel()
+
+	dir, tempErr := ioutil.TempDir("", "ladle-watch")
+	assert.Nil(t, tempErr)
+	defer os.RemoveAll(dir)
+
+	watcher, watcherErr := newDirWatcher()
+	assert.Nil(t, watcherErr)
+	defer watcher.Close()
+
+	assert.Nil(t, watcher.Add(dir))
+
+	path := filepath.Join(dir, "main.go")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("package main\n"), 0644))
+
+	select {
+	case changed := <-watcher.Events:
+		assert.Equal(t, path, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change event")
+	}
+}
+
+func TestDirWatcherClose(t *testing.T) {
+	t.Parallel()
+
+	dir, tempErr := ioutil.TempDir("", "ladle-watch")
+	assert.Nil(t, tempErr)
+	defer os.RemoveAll(dir)
+
+	watcher, watcherErr := newDirWatcher()
+	assert.Nil(t, watcherErr)
+	assert.Nil(t, watcher.Add(dir))
+
+	// leave a change unread, so the watcher is blocked sending it
+	path := filepath.Join(dir, "main.go")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("package main\n"), 0644))
+	time.Sleep(100 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() {
+		closed <- watcher.Close()
+	}()
+
+	select {
+	case closeErr := <-closed:
+		assert.Nil(t, closeErr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close didn't return")
+	}
+
+	for range watcher.Events {
+	}
+	assert.Nil(t, watcher.Close())
+}
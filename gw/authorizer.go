@@ -0,0 +1,439 @@
+package gw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/gofrs/uuid"
+	"github.com/nalanj/ladle/config"
+	"github.com/nalanj/ladle/rpc"
+)
+
+// The errors API Gateway responds with when a Lambda authorizer rejects a
+// request or fails
+var (
+	errUnauthorized = &gatewayError{
+		Status:  http.StatusUnauthorized,
+		Type:    "UnauthorizedException",
+		Message: "Unauthorized",
+	}
+	errAccessDenied = &gatewayError{
+		Status:  http.StatusForbidden,
+		Type:    "AccessDeniedException",
+		Message: "User is not authorized to access this resource",
+	}
+	errExplicitDeny = &gatewayError{
+		Status:  http.StatusForbidden,
+		Type:    "AccessDeniedException",
+		Message: "User is not authorized to access this resource with an explicit deny",
+	}
+	errAuthorizerConfiguration = &gatewayError{
+		Status:  http.StatusInternalServerError,
+		Type:    "AuthorizerConfigurationException",
+		Message: "Internal server error",
+	}
+)
+
+// authorizerResponse is the response of a Lambda authorizer. It's parsed
+// separately from APIGatewayCustomAuthorizerResponse since policies may give
+// a single action or resource as a string.
+type authorizerResponse struct {
+	PrincipalID    string                 `json:"principalId"`
+	PolicyDocument authorizerPolicy       `json:"policyDocument"`
+	Context        map[string]interface{} `json:"context"`
+}
+
+// authorizerPolicy is the IAM policy returned by an authorizer
+type authorizerPolicy struct {
+	Version   string
+	Statement []authorizerStatement
+}
+
+// authorizerStatement is a statement of an IAM policy
+type authorizerStatement struct {
+	Action   stringList
+	Effect   string
+	Resource stringList
+}
+
+// stringList is a list of strings that may be given in JSON as a single
+// string
+type stringList []string
+
+// UnmarshalJSON unmarshals a string or list of strings
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*l = stringList{single}
+		return nil
+	}
+
+	var list []string
+	if unmarshalErr := json.Unmarshal(data, &list); unmarshalErr != nil {
+		return unmarshalErr
+	}
+	*l = list
+
+	return nil
+}
+
+// authorizerCacheEntry is a cached authorizer response
+type authorizerCacheEntry struct {
+	resp    *authorizerResponse
+	expires time.Time
+}
+
+// authorizerCache caches authorizer responses by authorizer, function and
+// identity
+type authorizerCache struct {
+	mtx     sync.Mutex
+	entries map[string]authorizerCacheEntry
+}
+
+// newAuthorizerCache returns an empty authorizer cache
+func newAuthorizerCache() *authorizerCache {
+	return &authorizerCache{entries: make(map[string]authorizerCacheEntry)}
+}
+
+// get returns the cached response for the key, if it hasn't expired
+func (c *authorizerCache) get(key string, now time.Time) *authorizerResponse {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	if now.After(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+
+	return entry.resp
+}
+
+// put caches a response for the key until the ttl has passed
+func (c *authorizerCache) put(
+	key string,
+	resp *authorizerResponse,
+	now time.Time,
+	ttl time.Duration,
+) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.entries[key] = authorizerCacheEntry{resp: resp, expires: now.Add(ttl)}
+}
+
+// authorize runs the Lambda authorizer of the event a request was routed to,
+// if it has one. When the request is authorized, the authorizer's principal
+// and context are stored on the request for its function's request context.
+// Otherwise the error to respond with is returned along with its cause.
+func authorize(
+	g *gateway,
+	r *wrappedRequest,
+	event *config.Event,
+	pathParams map[string]string,
+) (*gatewayError, error) {
+	conf, i := g.conf, g.invoker
+
+	name := event.Meta["Authorizer"]
+	if name == "" {
+		return nil, nil
+	} else if name == config.JWTAuthorizerName {
+		return authorizeJWT(g, r, event)
+	}
+
+	auth := conf.API.Authorizers[name]
+	if auth == nil {
+		return errAuthorizerConfiguration, fmt.Errorf("Unknown authorizer %s", name)
+	}
+
+	identity, ok := identityValues(r.r, auth)
+	if !ok {
+		return errUnauthorized, errors.New("Missing identity source")
+	}
+
+	now := time.Now()
+	cacheKey := strings.Join(
+		append([]string{auth.Name, auth.Function}, identity...),
+		"\x00",
+	)
+	methodArn := methodArn(conf, r)
+
+	var resp *authorizerResponse
+	if auth.TTL > 0 {
+		resp = g.authorizers.get(cacheKey, now)
+	}
+
+	if resp == nil {
+		var gwErr *gatewayError
+		var cause error
+		resp, gwErr, cause = invokeAuthorizer(
+			conf, i, r, event, pathParams, auth, identity, methodArn,
+		)
+		if gwErr != nil {
+			return gwErr, cause
+		}
+
+		if auth.TTL > 0 {
+			g.authorizers.put(cacheKey, resp, now, auth.TTL)
+		}
+	}
+
+	if gwErr := evaluatePolicy(resp.PolicyDocument, methodArn); gwErr != nil {
+		return gwErr, fmt.Errorf(
+			"Authorizer %s denied %s to %s",
+			auth.Name,
+			methodArn,
+			resp.PrincipalID,
+		)
+	}
+
+	r.authorizer = map[string]interface{}{"principalId": resp.PrincipalID}
+	for key, val := range resp.Context {
+		r.authorizer[key] = val
+	}
+	r.httpAPIAuthorizer = map[string]interface{}{"lambda": r.authorizer}
+
+	return nil, nil
+}
+
+// invokeAuthorizer invokes an authorizer's function for a request
+func invokeAuthorizer(
+	conf *config.Config,
+	i rpc.Invoker,
+	r *wrappedRequest,
+	event *config.Event,
+	pathParams map[string]string,
+	auth *config.Authorizer,
+	identity []string,
+	methodArn string,
+) (*authorizerResponse, *gatewayError, error) {
+	var authReq interface{}
+	if auth.Type == config.RequestAuthorizer {
+		authReq = requestAuthorizerRequest(conf, r, event, pathParams, methodArn)
+	} else {
+		authReq = events.APIGatewayCustomAuthorizerRequest{
+			Type:               config.TokenAuthorizer,
+			AuthorizationToken: identity[0],
+			MethodArn:          methodArn,
+		}
+	}
+
+	payload, marshalErr := json.Marshal(authReq)
+	if marshalErr != nil {
+		return nil, errAuthorizerConfiguration, marshalErr
+	}
+
+	invokeReq := &messages.InvokeRequest{
+		RequestId:    uuid.Must(uuid.NewV4()).String(),
+		XAmznTraceId: r.r.Header.Get("X-Amzn-Trace-Id"),
+		Payload:      payload,
+	}
+
+	r.log(fmt.Sprintf("Authorizer %s", auth.Name))
+	resp, invokeErr := invokeWithTimeout(
+		i,
+		auth.Function,
+		invokeReq,
+		integrationTimeout(conf),
+	)
+	if invokeErr != nil {
+		return nil, errAuthorizerConfiguration, invokeErr
+	}
+
+	if resp.Error != nil {
+		if resp.Error.Message == errUnauthorized.Message {
+			return nil, errUnauthorized, errors.New("Authorizer returned Unauthorized")
+		}
+
+		return nil, errAuthorizerConfiguration, errors.New(resp.Error.Message)
+	}
+
+	authResp := &authorizerResponse{}
+	if unmarshalErr := json.Unmarshal(resp.Payload, authResp); unmarshalErr != nil {
+		return nil, errAuthorizerConfiguration, unmarshalErr
+	}
+
+	return authResp, nil, nil
+}
+
+// requestAuthorizerRequest builds the event for a REQUEST authorizer
+func requestAuthorizerRequest(
+	conf *config.Config,
+	r *wrappedRequest,
+	event *config.Event,
+	pathParams map[string]string,
+	methodArn string,
+) events.APIGatewayCustomAuthorizerRequestTypeRequest {
+	headers := make(map[string]string)
+	for k, v := range r.r.Header {
+		headers[k] = v[0]
+	}
+
+	query, multiValueQuery := queryParameters(r.r)
+	ctx := newProxyRequestContext(conf, r, event, time.Now())
+
+	return events.APIGatewayCustomAuthorizerRequestTypeRequest{
+		Type:                            config.RequestAuthorizer,
+		MethodArn:                       methodArn,
+		Resource:                        event.Meta["Route"],
+		Path:                            r.r.URL.Path,
+		HTTPMethod:                      r.r.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               r.r.Header,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: multiValueQuery,
+		PathParameters:                  pathParams,
+		StageVariables:                  r.stageVariables(),
+		RequestContext: events.APIGatewayCustomAuthorizerRequestTypeRequestContext{
+			Path:       ctx.Path,
+			AccountID:  ctx.AccountID,
+			ResourceID: ctx.ResourceID,
+			Stage:      ctx.Stage,
+			RequestID:  ctx.RequestID,
+			Identity: events.APIGatewayCustomAuthorizerRequestTypeRequestIdentity{
+				SourceIP: ctx.Identity.SourceIP,
+			},
+			ResourcePath: ctx.ResourcePath,
+			HTTPMethod:   ctx.HTTPMethod,
+			APIID:        ctx.APIID,
+		},
+	}
+}
+
+// identityValues returns the values of an authorizer's identity sources for
+// a request, and false if any of them are missing
+func identityValues(r *http.Request, auth *config.Authorizer) ([]string, bool) {
+	values := []string{}
+
+	for _, source := range auth.IdentitySource {
+		var value string
+		if strings.HasPrefix(source, "method.request.header.") {
+			value = r.Header.Get(strings.TrimPrefix(source, "method.request.header."))
+		} else if strings.HasPrefix(source, "method.request.querystring.") {
+			value = r.URL.Query().Get(
+				strings.TrimPrefix(source, "method.request.querystring."),
+			)
+		}
+
+		if value == "" {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+
+	return values, true
+}
+
+// methodArn returns the execute-api ARN of a request, which authorizer
+// policies are evaluated against
+func methodArn(conf *config.Config, r *wrappedRequest) string {
+	region := conf.Region
+	if region == "" {
+		region = config.DefaultRegion
+	}
+
+	return fmt.Sprintf(
+		"arn:aws:execute-api:%s:%s:%s/%s/%s%s",
+		region,
+		accountID(conf),
+		apiID(conf),
+		r.stageName(conf),
+		r.r.Method,
+		r.r.URL.Path,
+	)
+}
+
+// evaluatePolicy evaluates an authorizer's policy for invoking the method
+// ARN. Like IAM, an explicit deny overrides any allow, and anything not
+// allowed is denied.
+func evaluatePolicy(policy authorizerPolicy, methodArn string) *gatewayError {
+	allowed := false
+
+	for _, statement := range policy.Statement {
+		if !statementMatches(statement, methodArn) {
+			continue
+		}
+
+		switch strings.ToLower(statement.Effect) {
+		case "deny":
+			return errExplicitDeny
+		case "allow":
+			allowed = true
+		}
+	}
+
+	if !allowed {
+		return errAccessDenied
+	}
+
+	return nil
+}
+
+// statementMatches returns true if a policy statement applies to invoking
+// the method ARN
+func statementMatches(statement authorizerStatement, methodArn string) bool {
+	actionMatch := false
+	for _, action := range statement.Action {
+		if wildcardMatch(strings.ToLower(action), "execute-api:invoke") {
+			actionMatch = true
+		}
+	}
+
+	if !actionMatch {
+		return false
+	}
+
+	for _, resource := range statement.Resource {
+		if wildcardMatch(resource, methodArn) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wildcardMatch matches a value against an IAM pattern, where * matches any
+// run of characters and ? matches any single character
+func wildcardMatch(pattern, value string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+
+			for i := 0; i <= len(value); i++ {
+				if wildcardMatch(pattern, value[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if value == "" {
+				return false
+			}
+		default:
+			if value == "" || pattern[0] != value[0] {
+				return false
+			}
+		}
+
+		pattern = pattern[1:]
+		value = value[1:]
+	}
+
+	return value == ""
+}
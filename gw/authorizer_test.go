@@ -0,0 +1,393 @@
+package gw
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/nalanj/ladle/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	allowPolicy := `{
+		"principalId": "user-1",
+		"policyDocument": {
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Action": "execute-api:Invoke",
+				"Effect": "Allow",
+				"Resource": "arn:aws:execute-api:us-east-1:*:*/local/GET/users/*"
+			}]
+		},
+		"context": {"tenant": "acme", "admin": true}
+	}`
+	denyPolicy := `{
+		"principalId": "user-2",
+		"policyDocument": {
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Action": ["execute-api:Invoke"],
+				"Effect": "Deny",
+				"Resource": ["*"]
+			}]
+		}
+	}`
+
+	tests := []struct {
+		name      string
+		authType  string
+		ttl       time.Duration
+		token     string
+		path      string
+		invokes   int32
+		gwErr     *gatewayError
+		principal string
+	}{
+		{
+			name:      "allows an allowed token",
+			token:     "allow",
+			path:      "/users/12",
+			invokes:   1,
+			principal: "user-1",
+		},
+		{
+			name:    "denies a resource the policy doesn't allow",
+			token:   "allow",
+			path:    "/accounts/12",
+			invokes: 1,
+			gwErr:   errAccessDenied,
+		},
+		{
+			name:    "denies an explicitly denied token",
+			token:   "deny",
+			path:    "/users/12",
+			invokes: 1,
+			gwErr:   errExplicitDeny,
+		},
+		{
+			name:    "rejects a missing token without invoking",
+			path:    "/users/12",
+			invokes: 0,
+			gwErr:   errUnauthorized,
+		},
+		{
+			name:    "rejects an unauthorized token",
+			token:   "unauthorized",
+			path:    "/users/12",
+			invokes: 1,
+			gwErr:   errUnauthorized,
+		},
+		{
+			name:    "fails on an authorizer error",
+			token:   "error",
+			path:    "/users/12",
+			invokes: 1,
+			gwErr:   errAuthorizerConfiguration,
+		},
+		{
+			name:      "caches results for the ttl",
+			ttl:       time.Minute,
+			token:     "allow",
+			path:      "/users/12",
+			invokes:   1,
+			principal: "user-1",
+		},
+		{
+			name:      "passes the request to request authorizers",
+			authType:  config.RequestAuthorizer,
+			token:     "allow",
+			path:      "/users/12",
+			invokes:   2,
+			principal: "user-1",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			authType := test.authType
+			if authType == "" {
+				authType = config.TokenAuthorizer
+			}
+
+			conf := &config.Config{
+				API: config.API{
+					Authorizers: map[string]*config.Authorizer{
+						test.name: &config.Authorizer{
+							Name:           test.name,
+							Function:       "Auth",
+							Type:           authType,
+							IdentitySource: config.DefaultIdentitySource,
+							TTL:            test.ttl,
+						},
+					},
+				},
+			}
+			event := &config.Event{
+				Source: config.APISource,
+				Target: "Users",
+				Meta: map[string]string{
+					"Route":      "/{resource}/{id}",
+					"Authorizer": test.name,
+				},
+			}
+
+			var invokes int32
+			invoker := func(
+				name string,
+				req *messages.InvokeRequest,
+				resp *messages.InvokeResponse,
+			) error {
+				atomic.AddInt32(&invokes, 1)
+				assert.Equal(t, "Auth", name)
+
+				token := ""
+				if authType == config.RequestAuthorizer {
+					authReq := events.APIGatewayCustomAuthorizerRequestTypeRequest{}
+					assert.Nil(t, json.Unmarshal(req.Payload, &authReq))
+					assert.Equal(t, "12", authReq.PathParameters["id"])
+					token = authReq.Headers["Authorization"]
+				} else {
+					authReq := events.APIGatewayCustomAuthorizerRequest{}
+					assert.Nil(t, json.Unmarshal(req.Payload, &authReq))
+					token = authReq.AuthorizationToken
+				}
+
+				switch token {
+				case "allow":
+					resp.Payload = []byte(allowPolicy)
+				case "deny":
+					resp.Payload = []byte(denyPolicy)
+				case "unauthorized":
+					resp.Error = &messages.InvokeResponse_Error{Message: "Unauthorized"}
+				default:
+					resp.Error = &messages.InvokeResponse_Error{Message: "boom"}
+				}
+
+				return nil
+			}
+
+			// authorize twice to exercise caching
+			g := newGateway(conf, invoker)
+			for i := 0; i < 2; i++ {
+				req, reqErr := http.NewRequest(
+					"GET",
+					"https://testing.com"+test.path,
+					bytes.NewReader(nil),
+				)
+				assert.Nil(t, reqErr)
+				if test.token != "" {
+					req.Header.Set("Authorization", test.token)
+				}
+
+				wr := newRequest(req)
+				_, params, _ := newRouteTree(
+					[]*config.Event{event},
+				).route(req)
+
+				gwErr, _ := authorize(g, wr, event, params)
+				assert.Equal(t, test.gwErr, gwErr)
+
+				if test.principal != "" {
+					assert.Equal(t, test.principal, wr.authorizer["principalId"])
+					assert.Equal(t, "acme", wr.authorizer["tenant"])
+					assert.Equal(t, true, wr.authorizer["admin"])
+				}
+			}
+
+			expectedInvokes := test.invokes
+			if test.ttl == 0 && test.invokes > 0 {
+				expectedInvokes = 2
+			}
+			assert.Equal(t, expectedInvokes, atomic.LoadInt32(&invokes))
+		})
+	}
+}
+
+func TestAuthorizeCache(t *testing.T) {
+	t.Parallel()
+
+	auth := &config.Authorizer{
+		Name:           "Auth",
+		Function:       "Auth",
+		Type:           config.TokenAuthorizer,
+		IdentitySource: config.DefaultIdentitySource,
+		TTL:            time.Minute,
+	}
+	conf := &config.Config{
+		API: config.API{
+			Authorizers: map[string]*config.Authorizer{"Auth": auth},
+		},
+	}
+	event := &config.Event{
+		Source: config.APISource,
+		Target: "Users",
+		Meta:   map[string]string{"Route": "/users", "Authorizer": "Auth"},
+	}
+
+	invoked := []string{}
+	invoker := func(
+		name string,
+		req *messages.InvokeRequest,
+		resp *messages.InvokeResponse,
+	) error {
+		invoked = append(invoked, name)
+		resp.Payload = []byte(`{
+			"principalId": "user-1",
+			"policyDocument": {
+				"Version": "2012-10-17",
+				"Statement": [{
+					"Action": "execute-api:Invoke",
+					"Effect": "Allow",
+					"Resource": "*"
+				}]
+			}
+		}`)
+		return nil
+	}
+
+	authorizeWith := func(g *gateway) {
+		req, reqErr := http.NewRequest("GET", "https://testing.com/users", nil)
+		assert.Nil(t, reqErr)
+		req.Header.Set("Authorization", "allow")
+
+		gwErr, _ := authorize(g, newRequest(req), event, nil)
+		assert.Nil(t, gwErr)
+	}
+
+	g := newGateway(conf, invoker)
+	authorizeWith(g)
+	authorizeWith(g)
+	assert.Equal(t, []string{"Auth"}, invoked)
+
+	// a handler doesn't share its cache with other handlers
+	authorizeWith(newGateway(conf, invoker))
+	assert.Equal(t, []string{"Auth", "Auth"}, invoked)
+
+	// responses are cached per function
+	auth.Function = "OtherAuth"
+	authorizeWith(g)
+	assert.Equal(t, []string{"Auth", "Auth", "OtherAuth"}, invoked)
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	t.Parallel()
+
+	arn := "arn:aws:execute-api:us-east-1:123456789012:ladle00000/local/GET/users/12"
+
+	tests := []struct {
+		name       string
+		statements []authorizerStatement
+		want       *gatewayError
+	}{
+		{
+			"allows an exact resource",
+			[]authorizerStatement{
+				{Action: stringList{"execute-api:Invoke"}, Effect: "Allow", Resource: stringList{arn}},
+			},
+			nil,
+		},
+		{
+			"allows a wildcard action and resource",
+			[]authorizerStatement{
+				{Action: stringList{"execute-api:*"}, Effect: "Allow", Resource: stringList{"*"}},
+			},
+			nil,
+		},
+		{
+			"denies with no statements",
+			nil,
+			errAccessDenied,
+		},
+		{
+			"denies another action",
+			[]authorizerStatement{
+				{Action: stringList{"s3:GetObject"}, Effect: "Allow", Resource: stringList{"*"}},
+			},
+			errAccessDenied,
+		},
+		{
+			"lets an explicit deny override an allow",
+			[]authorizerStatement{
+				{Action: stringList{"*"}, Effect: "Allow", Resource: stringList{"*"}},
+				{
+					Action:   stringList{"execute-api:Invoke"},
+					Effect:   "Deny",
+					Resource: stringList{"arn:aws:execute-api:*:*:*/*/GET/users/?2"},
+				},
+			},
+			errExplicitDeny,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := authorizerPolicy{Statement: test.statements}
+			assert.Equal(t, test.want, evaluatePolicy(policy, arn))
+		})
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"a*", "abc", true},
+		{"*c", "abc", true},
+		{"a*c", "ac", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"a*b*c", "a/x/b/y/c", true},
+		{"a*b*c", "a/x/c", false},
+		{"*", "", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(
+			t,
+			test.match,
+			wildcardMatch(test.pattern, test.value),
+			test.pattern+" "+test.value,
+		)
+	}
+}
+
+func TestPrepareRequestAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	req, reqErr := http.NewRequest("GET", "https://testing.com/users", bytes.NewReader(nil))
+	assert.Nil(t, reqErr)
+
+	wr := newRequest(req)
+	wr.authorizer = map[string]interface{}{"principalId": "user-1", "tenant": "acme"}
+
+	ri, prepErr := wr.prepareRequest(
+		&config.Config{},
+		&config.Event{Source: config.APISource},
+		nil,
+	)
+	assert.Nil(t, prepErr)
+
+	gwR := &events.APIGatewayProxyRequest{}
+	assert.Nil(t, json.Unmarshal(ri.Payload, gwR))
+	assert.Equal(t, wr.authorizer, gwR.RequestContext.Authorizer)
+}
@@ -0,0 +1,113 @@
+package gw
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nalanj/ladle/config"
+)
+
+// isPreflight returns true if a request is a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// preflightCORS returns the CORS configuration of the route a preflight
+// request asks about, or nil if CORS isn't configured for it
+func preflightCORS(
+	conf *config.Config, routes *routeNode, r *http.Request,
+) *config.CORS {
+	requested := *r
+	requested.Method = r.Header.Get("Access-Control-Request-Method")
+
+	event, _, _ := routes.route(&requested)
+	return conf.CORSFor(event)
+}
+
+// corsHeaders returns the CORS headers of a response to a request, or nil if
+// the request isn't a cross-origin request from an allowed origin
+func corsHeaders(cors *config.CORS, r *http.Request) http.Header {
+	origin := r.Header.Get("Origin")
+	if cors == nil || origin == "" || !cors.AllowsOrigin(origin) {
+		return nil
+	}
+
+	headers := make(http.Header)
+	if contains(cors.AllowOrigins, "*") {
+		headers.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		headers.Set("Access-Control-Allow-Origin", origin)
+		headers.Set("Vary", "Origin")
+	}
+
+	if cors.AllowCredentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if len(cors.ExposeHeaders) > 0 {
+		headers.Set(
+			"Access-Control-Expose-Headers",
+			strings.Join(cors.ExposeHeaders, ","),
+		)
+	}
+
+	return headers
+}
+
+// writePreflight responds to a preflight request without invoking a
+// function, as API Gateway does when CORS is configured. Preflights from
+// origins that aren't allowed get a response without CORS headers.
+func writePreflight(w http.ResponseWriter, r *http.Request, cors *config.CORS) {
+	headers := corsHeaders(cors, r)
+	if headers != nil {
+		headers.Del("Access-Control-Expose-Headers")
+
+		methods := strings.Join(cors.AllowMethods, ",")
+		if contains(cors.AllowMethods, "*") {
+			methods = r.Header.Get("Access-Control-Request-Method")
+		}
+		if methods != "" {
+			headers.Set("Access-Control-Allow-Methods", methods)
+		}
+
+		allowHeaders := strings.Join(cors.AllowHeaders, ",")
+		if contains(cors.AllowHeaders, "*") {
+			allowHeaders = r.Header.Get("Access-Control-Request-Headers")
+		}
+		if allowHeaders != "" {
+			headers.Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+
+		if cors.MaxAge > 0 {
+			headers.Set(
+				"Access-Control-Max-Age",
+				strconv.Itoa(int(cors.MaxAge.Seconds())),
+			)
+		}
+
+		setHeaders(w, headers)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setHeaders sets headers on a response, replacing any of the same name
+func setHeaders(w http.ResponseWriter, headers http.Header) {
+	for key, vals := range headers {
+		w.Header()[key] = vals
+	}
+}
+
+// contains returns true if a list contains the value
+func contains(list []string, val string) bool {
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,207 @@
+package gw
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda/messages"
+
+	"github.com/nalanj/ladle/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvokeCORS(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		status  int
+		invoked bool
+		want    map[string]string
+	}{
+		{
+			name:   "answers preflights without invoking",
+			method: "OPTIONS",
+			path:   "/echo",
+			headers: map[string]string{
+				"Origin":                         "https://example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "content-type,x-api-key",
+			},
+			status: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Methods":     "GET,POST",
+				"Access-Control-Allow-Headers":     "content-type,x-api-key",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Max-Age":           "600",
+				"Access-Control-Expose-Headers":    "",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:   "answers preflights from other origins without cors headers",
+			method: "OPTIONS",
+			path:   "/echo",
+			headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "POST",
+			},
+			status: http.StatusNoContent,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "answers preflights with a route's cors",
+			method: "OPTIONS",
+			path:   "/public",
+			headers: map[string]string{
+				"Origin":                        "https://other.example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			status: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET",
+				"Vary":                         "",
+			},
+		},
+		{
+			name:   "passes preflights to routes without cors",
+			method: "OPTIONS",
+			path:   "/private",
+			headers: map[string]string{
+				"Origin":                        "https://example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			status:  http.StatusOK,
+			invoked: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin": "https://function.example.com",
+			},
+		},
+		{
+			name:    "replaces the function's cors headers",
+			method:  "POST",
+			path:    "/echo",
+			headers: map[string]string{"Origin": "https://example.com"},
+			status:  http.StatusOK,
+			invoked: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-Id",
+				"Access-Control-Allow-Methods":     "",
+			},
+		},
+		{
+			name:    "adds cors headers to http api responses",
+			method:  "GET",
+			path:    "/public",
+			headers: map[string]string{"Origin": "https://other.example.com"},
+			status:  http.StatusOK,
+			invoked: true,
+			want:    map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:    "adds cors headers to gateway errors",
+			method:  "GET",
+			path:    "/not-found",
+			headers: map[string]string{"Origin": "https://example.com"},
+			status:  http.StatusForbidden,
+			want: map[string]string{
+				"Access-Control-Allow-Origin": "https://example.com",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config.Config{
+				API: config.API{
+					CORS: &config.CORS{
+						AllowOrigins:     []string{"https://example.com"},
+						AllowMethods:     []string{"GET", "POST"},
+						AllowHeaders:     []string{"*"},
+						ExposeHeaders:    []string{"X-Request-Id"},
+						AllowCredentials: true,
+						MaxAge:           600 * time.Second,
+					},
+				},
+				Functions: map[string]*config.Function{
+					"Echo": &config.Function{Name: "Echo"},
+				},
+				Events: []*config.Event{
+					&config.Event{
+						Source: config.APISource,
+						Target: "Echo",
+						Meta:   map[string]string{"Route": "/echo"},
+					},
+					&config.Event{
+						Source: config.APISource,
+						Target: "Echo",
+						Meta: map[string]string{
+							"Route":                "/public",
+							"PayloadVersion":       config.PayloadVersion2,
+							"CORSAllowOrigins":     "*",
+							"CORSAllowMethods":     "*",
+							"CORSAllowCredentials": "false",
+							"CORSExposeHeaders":    "",
+						},
+					},
+					&config.Event{
+						Source: config.APISource,
+						Target: "Echo",
+						Meta:   map[string]string{"Route": "/private", "CORS": "false"},
+					},
+				},
+			}
+
+			invoked := false
+			invoker := func(
+				name string,
+				req *messages.InvokeRequest,
+				resp *messages.InvokeResponse,
+			) error {
+				invoked = true
+
+				data, marshalErr := json.Marshal(
+					&events.APIGatewayProxyResponse{
+						StatusCode: http.StatusOK,
+						Headers: map[string]string{
+							"Access-Control-Allow-Origin": "https://function.example.com",
+						},
+					},
+				)
+				assert.Nil(t, marshalErr)
+				resp.Payload = data
+
+				return nil
+			}
+
+			req := httptest.NewRequest(test.method, test.path, bytes.NewReader(nil))
+			for key, val := range test.headers {
+				req.Header.Set(key, val)
+			}
+			w := httptest.NewRecorder()
+
+			invoke(newGateway(cfg, invoker), w, newRequest(req))
+
+			assert.Equal(t, test.status, w.Code)
+			assert.Equal(t, test.invoked, invoked)
+			for key, val := range test.want {
+				assert.Equal(t, val, w.Header().Get(key), key)
+			}
+		})
+	}
+}
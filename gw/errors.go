@@ -0,0 +1,114 @@
+package gw
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/nalanj/ladle/config"
+)
+
+// gatewayError is an error response written by the gateway itself, rather
+// than by a function
+type gatewayError struct {
+	// Status is the http status of the response
+	Status int
+
+	// Type is sent in the x-amzn-ErrorType header
+	Type string
+
+	// Message is sent as the message of the response body
+	Message string
+
+	// Header holds any other headers sent with the response
+	Header http.Header
+}
+
+// The errors API Gateway responds with when requests can't be passed to a
+// function or functions don't respond successfully
+var (
+	errMissingAuthenticationToken = &gatewayError{
+		Status:  http.StatusForbidden,
+		Type:    "MissingAuthenticationTokenException",
+		Message: "Missing Authentication Token",
+	}
+	errInternalServerError = &gatewayError{
+		Status:  http.StatusInternalServerError,
+		Type:    "InternalServerErrorException",
+		Message: "Internal server error",
+	}
+	errBadGateway = &gatewayError{
+		Status:  http.StatusBadGateway,
+		Type:    "InternalServerErrorException",
+		Message: "Internal server error",
+	}
+	errEndpointTimeout = &gatewayError{
+		Status:  http.StatusGatewayTimeout,
+		Type:    "InternalServerErrorException",
+		Message: "Endpoint request timed out",
+	}
+	errTooManyRequests = &gatewayError{
+		Status:  http.StatusTooManyRequests,
+		Type:    "TooManyRequestsException",
+		Message: "Too Many Requests",
+	}
+)
+
+// The errors HTTP APIs respond with in place of those above
+var (
+	errHTTPAPIInternalServerError = &gatewayError{
+		Status:  http.StatusInternalServerError,
+		Type:    "InternalServerErrorException",
+		Message: "Internal Server Error",
+	}
+	errHTTPAPIServiceUnavailable = &gatewayError{
+		Status:  http.StatusServiceUnavailable,
+		Type:    "ServiceUnavailableException",
+		Message: "Service Unavailable",
+	}
+)
+
+// gatewayErrorBody is the body of a gateway error response. The fields other
+// than Message are only included when the API has VerboseErrors set.
+type gatewayErrorBody struct {
+	Message      string                                      `json:"message"`
+	ErrorMessage string                                      `json:"errorMessage,omitempty"`
+	ErrorType    string                                      `json:"errorType,omitempty"`
+	StackTrace   []*messages.InvokeResponse_Error_StackFrame `json:"stackTrace,omitempty"`
+	Cause        string                                      `json:"cause,omitempty"`
+}
+
+// writeGatewayError writes a gateway error response. When the API has
+// VerboseErrors set, the cause of the error is included in the body.
+func writeGatewayError(
+	conf *config.Config,
+	w http.ResponseWriter,
+	gwErr *gatewayError,
+	cause error,
+	lambdaErr *messages.InvokeResponse_Error,
+) {
+	body := gatewayErrorBody{Message: gwErr.Message}
+	if conf.API.VerboseErrors {
+		if cause != nil {
+			body.Cause = cause.Error()
+		}
+
+		if lambdaErr != nil {
+			body.ErrorMessage = lambdaErr.Message
+			body.ErrorType = lambdaErr.Type
+			body.StackTrace = lambdaErr.StackTrace
+		}
+	}
+
+	data, _ := json.Marshal(body)
+
+	for key, vals := range gwErr.Header {
+		for _, val := range vals {
+			w.Header().Add(key, val)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-amzn-ErrorType", gwErr.Type)
+	w.WriteHeader(gwErr.Status)
+	w.Write(data)
+}
@@ -0,0 +1,271 @@
+package gw
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/nalanj/ladle/config"
+)
+
+// httpAPIRequest is the payload format 2.0 event HTTP APIs invoke functions
+// with
+type httpAPIRequest struct {
+	Version               string                `json:"version"`
+	RouteKey              string                `json:"routeKey"`
+	RawPath               string                `json:"rawPath"`
+	RawQueryString        string                `json:"rawQueryString"`
+	Cookies               []string              `json:"cookies,omitempty"`
+	Headers               map[string]string     `json:"headers"`
+	QueryStringParameters map[string]string     `json:"queryStringParameters,omitempty"`
+	PathParameters        map[string]string     `json:"pathParameters,omitempty"`
+	StageVariables        map[string]string     `json:"stageVariables,omitempty"`
+	RequestContext        httpAPIRequestContext `json:"requestContext"`
+	Body                  string                `json:"body,omitempty"`
+	IsBase64Encoded       bool                  `json:"isBase64Encoded"`
+}
+
+// httpAPIRequestContext is the request context of an httpAPIRequest
+type httpAPIRequestContext struct {
+	AccountID    string                 `json:"accountId"`
+	APIID        string                 `json:"apiId"`
+	Authorizer   map[string]interface{} `json:"authorizer,omitempty"`
+	DomainName   string                 `json:"domainName"`
+	DomainPrefix string                 `json:"domainPrefix"`
+	HTTP         httpAPIRequestHTTP     `json:"http"`
+	RequestID    string                 `json:"requestId"`
+	RouteKey     string                 `json:"routeKey"`
+	Stage        string                 `json:"stage"`
+	Time         string                 `json:"time"`
+	TimeEpoch    int64                  `json:"timeEpoch"`
+}
+
+// httpAPIRequestHTTP describes the http request of an httpAPIRequest
+type httpAPIRequestHTTP struct {
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Protocol  string `json:"protocol"`
+	SourceIP  string `json:"sourceIp"`
+	UserAgent string `json:"userAgent"`
+}
+
+// httpAPIResponse is the payload format 2.0 response functions return to
+// HTTP APIs
+type httpAPIResponse struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers"`
+	Cookies         []string          `json:"cookies"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+// prepareHTTPAPIRequest converts an http.Request into a payload format 2.0
+// InvokeRequest for the event it was routed to
+func (r *wrappedRequest) prepareHTTPAPIRequest(
+	conf *config.Config,
+	event *config.Event,
+	pathParams map[string]string,
+) (*messages.InvokeRequest, error) {
+	body, bodyErr := ioutil.ReadAll(r.r.Body)
+	if bodyErr != nil {
+		return nil, bodyErr
+	}
+
+	// HTTP APIs combine repeated headers and query parameters with commas and
+	// pass cookies separately
+	headers := make(map[string]string)
+	var cookies []string
+	for k, v := range r.r.Header {
+		if k == "Cookie" {
+			for _, cookie := range v {
+				cookies = append(cookies, strings.Split(cookie, "; ")...)
+			}
+			continue
+		}
+
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+
+	var query map[string]string
+	if values := r.r.URL.Query(); len(values) > 0 {
+		query = make(map[string]string, len(values))
+		for k, v := range values {
+			query[k] = strings.Join(v, ",")
+		}
+	}
+
+	if len(pathParams) == 0 {
+		pathParams = nil
+	}
+
+	bodyString := string(body)
+	isBase64Encoded := len(body) > 0 &&
+		!isTextMediaType(r.r.Header.Get("Content-Type"))
+	if isBase64Encoded {
+		bodyString = base64.StdEncoding.EncodeToString(body)
+	}
+
+	routeKey := httpAPIRouteKey(r.r, event)
+	now := time.Now()
+
+	gwR := httpAPIRequest{
+		Version:               config.PayloadVersion2,
+		RouteKey:              routeKey,
+		RawPath:               r.rawPath(),
+		RawQueryString:        r.r.URL.RawQuery,
+		Cookies:               cookies,
+		Headers:               headers,
+		QueryStringParameters: query,
+		PathParameters:        pathParams,
+		StageVariables:        r.stageVariables(),
+		Body:                  bodyString,
+		IsBase64Encoded:       isBase64Encoded,
+		RequestContext: httpAPIRequestContext{
+			AccountID:    accountID(conf),
+			APIID:        apiID(conf),
+			Authorizer:   r.httpAPIAuthorizer,
+			DomainName:   r.r.Host,
+			DomainPrefix: domainPrefix(r.r.Host),
+			HTTP: httpAPIRequestHTTP{
+				Method:    r.r.Method,
+				Path:      r.requestPath(),
+				Protocol:  r.r.Proto,
+				SourceIP:  sourceIP(r.r),
+				UserAgent: r.r.UserAgent(),
+			},
+			RequestID: r.id,
+			RouteKey:  routeKey,
+			Stage:     r.stageName(conf),
+			Time:      now.UTC().Format(requestTimeFormat),
+			TimeEpoch: now.UnixNano() / int64(time.Millisecond),
+		},
+	}
+
+	payload, marshalErr := json.Marshal(gwR)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+
+	return &messages.InvokeRequest{
+		RequestId:    r.id,
+		XAmznTraceId: r.r.Header.Get("X-Amzn-Trace-Id"),
+		Payload:      payload,
+	}, nil
+}
+
+// httpAPIRouteKey returns the route key, like GET /users/{id}, of the route a
+// request matched
+func httpAPIRouteKey(r *http.Request, event *config.Event) string {
+	method := config.AnyMethod
+	if event.Methods() != nil {
+		method = r.Method
+	}
+
+	return method + " " + event.Meta["Route"]
+}
+
+// writeHTTPAPIResponse writes an http response based on the given payload
+// format 2.0 InvokeResponse. Like HTTP APIs, a response that isn't an object
+// with a statusCode is taken to be the body of a 200 JSON response. The
+// gateway's CORS headers replace any the function returned.
+func writeHTTPAPIResponse(
+	w http.ResponseWriter, resp *messages.InvokeResponse, cors http.Header,
+) error {
+	gwResp, parseErr := parseHTTPAPIResponse(resp.Payload)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	if !validStatusCode(gwResp.StatusCode) {
+		return fmt.Errorf("Invalid statusCode %d", gwResp.StatusCode)
+	}
+
+	body := []byte(gwResp.Body)
+	if gwResp.IsBase64Encoded {
+		decoded, decodeErr := base64.StdEncoding.DecodeString(gwResp.Body)
+		if decodeErr != nil {
+			return decodeErr
+		}
+		body = decoded
+	}
+
+	for key, val := range gwResp.Headers {
+		w.Header().Set(key, val)
+	}
+	for _, cookie := range gwResp.Cookies {
+		w.Header().Add("Set-Cookie", cookie)
+	}
+	setHeaders(w, cors)
+
+	w.WriteHeader(gwResp.StatusCode)
+	w.Write(body)
+
+	return nil
+}
+
+// parseHTTPAPIResponse parses a payload format 2.0 response, inferring the
+// response format when the payload doesn't include a statusCode
+func parseHTTPAPIResponse(payload []byte) (*httpAPIResponse, error) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(payload, &fields) == nil {
+		if _, ok := fields["statusCode"]; ok {
+			gwResp := &httpAPIResponse{}
+			if unmarshalErr := json.Unmarshal(payload, gwResp); unmarshalErr != nil {
+				return nil, unmarshalErr
+			}
+
+			return gwResp, nil
+		}
+	}
+
+	var body interface{}
+	if unmarshalErr := json.Unmarshal(payload, &body); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	gwResp := &httpAPIResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(payload),
+	}
+	if str, ok := body.(string); ok {
+		gwResp.Body = str
+	}
+
+	return gwResp, nil
+}
+
+// isTextMediaType returns true if HTTP APIs pass bodies of the content type
+// to functions as text rather than base64 encoded
+func isTextMediaType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, parseErr := mime.ParseMediaType(contentType)
+	if parseErr != nil {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+
+	switch mediaType {
+	case "application/json",
+		"application/javascript",
+		"application/xml",
+		"application/x-www-form-urlencoded":
+		return true
+	}
+
+	return false
+}
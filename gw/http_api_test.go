@@ -0,0 +1,187 @@
+package gw
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/nalanj/ladle/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPrepareHTTPAPIRequestTime isn't parallel since it changes the local
+// time zone
+func TestPrepareHTTPAPIRequestTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { time.Local = local }()
+
+	req, reqErr := http.NewRequest(
+		"GET",
+		"https://testing.com/users",
+		bytes.NewReader(nil),
+	)
+	assert.Nil(t, reqErr)
+
+	event := &config.Event{
+		Source: config.APISource,
+		Meta: map[string]string{
+			"Route":          "/users",
+			"PayloadVersion": config.PayloadVersion2,
+		},
+	}
+
+	ri, prepErr := newRequest(req).prepareHTTPAPIRequest(
+		&config.Config{},
+		event,
+		nil,
+	)
+	assert.Nil(t, prepErr)
+
+	gwR := &httpAPIRequest{}
+	assert.Nil(t, json.Unmarshal(ri.Payload, gwR))
+	assert.Regexp(t, ` \+0000$`, gwR.RequestContext.Time)
+}
+
+func TestPrepareHTTPAPIRequest(t *testing.T) {
+	t.Parallel()
+
+	req, reqErr := http.NewRequest(
+		"POST",
+		"https://testing.com:3030/users/12?id=1&id=2&page=3",
+		bytes.NewReader([]byte("testBody")),
+	)
+	assert.Nil(t, reqErr)
+	req.Header.Add("Rando-Header", "Value1")
+	req.Header.Add("Rando-Header", "Value2")
+	req.Header.Add("Cookie", "session=abc; theme=dark")
+	req.Header.Set("User-Agent", "ladle-test")
+	req.RemoteAddr = "127.0.0.1:45678"
+
+	event := &config.Event{
+		Source: config.APISource,
+		Meta: map[string]string{
+			"Route":          "/users/{id}",
+			"Method":         "POST",
+			"PayloadVersion": config.PayloadVersion2,
+		},
+	}
+
+	wr := newRequest(req)
+	ri, prepErr := wr.prepareHTTPAPIRequest(
+		&config.Config{AccountID: "000000000000"},
+		event,
+		map[string]string{"id": "12"},
+	)
+	assert.Nil(t, prepErr)
+	assert.Equal(t, wr.id, ri.RequestId)
+
+	gwR := &httpAPIRequest{}
+	assert.Nil(t, json.Unmarshal(ri.Payload, gwR))
+
+	assert.Equal(t, "2.0", gwR.Version)
+	assert.Equal(t, "POST /users/{id}", gwR.RouteKey)
+	assert.Equal(t, "/users/12", gwR.RawPath)
+	assert.Equal(t, "id=1&id=2&page=3", gwR.RawQueryString)
+	assert.Equal(t, []string{"session=abc", "theme=dark"}, gwR.Cookies)
+	assert.Equal(t, "Value1,Value2", gwR.Headers["rando-header"])
+	assert.NotContains(t, gwR.Headers, "cookie")
+	assert.Equal(
+		t,
+		map[string]string{"id": "1,2", "page": "3"},
+		gwR.QueryStringParameters,
+	)
+	assert.Equal(t, map[string]string{"id": "12"}, gwR.PathParameters)
+	assert.Equal(t, "testBody", gwR.Body)
+	assert.False(t, gwR.IsBase64Encoded)
+
+	ctx := gwR.RequestContext
+	assert.Equal(t, "000000000000", ctx.AccountID)
+	assert.Equal(t, wr.id, ctx.RequestID)
+	assert.Equal(t, "POST /users/{id}", ctx.RouteKey)
+	assert.Equal(t, config.DefaultStage, ctx.Stage)
+	assert.Equal(t, config.DefaultAPIID, ctx.APIID)
+	assert.Equal(t, "POST", ctx.HTTP.Method)
+	assert.Equal(t, "/users/12", ctx.HTTP.Path)
+	assert.Equal(t, "127.0.0.1", ctx.HTTP.SourceIP)
+	assert.Equal(t, "ladle-test", ctx.HTTP.UserAgent)
+	assert.NotZero(t, ctx.TimeEpoch)
+}
+
+func TestParseHTTPAPIResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		payload string
+		want    *httpAPIResponse
+		wantErr bool
+	}{
+		{
+			"full response",
+			`{"statusCode":201,"headers":{"X-Test":"yes"},"cookies":["a=1"],"body":"hi"}`,
+			&httpAPIResponse{
+				StatusCode: 201,
+				Headers:    map[string]string{"X-Test": "yes"},
+				Cookies:    []string{"a=1"},
+				Body:       "hi",
+			},
+			false,
+		},
+		{
+			"bare string",
+			`"Hello from Lambda!"`,
+			&httpAPIResponse{
+				StatusCode: http.StatusOK,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Body:       "Hello from Lambda!",
+			},
+			false,
+		},
+		{
+			"object without status code",
+			`{"message":"hi"}`,
+			&httpAPIResponse{
+				StatusCode: http.StatusOK,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Body:       `{"message":"hi"}`,
+			},
+			false,
+		},
+		{"invalid json", `{"message":`, nil, true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseHTTPAPIResponse([]byte(test.payload))
+			assert.Equal(t, test.wantErr, err != nil)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestWriteHTTPAPIResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	writeErr := writeHTTPAPIResponse(
+		w,
+		&messages.InvokeResponse{
+			Payload: []byte(
+				`{"statusCode":200,"cookies":["a=1","b=2"],"body":"aGk=","isBase64Encoded":true}`,
+			),
+		},
+		nil,
+	)
+	assert.Nil(t, writeErr)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hi", w.Body.String())
+	assert.Equal(t, []string{"a=1", "b=2"}, w.Result().Header["Set-Cookie"])
+}
@@ -1,7 +1,9 @@
 package gw
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -19,6 +21,7 @@ import (
 // InvokeHandler returns a handler that can invoke called functions via http
 func InvokeHandler(conf *config.Config, i rpc.Invoker) http.Handler {
 	fs := http.FileServer(http.Dir(conf.PublicDir()))
+	g := newGateway(conf, i)
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		startTime := time.Now()
@@ -34,7 +37,7 @@ func InvokeHandler(conf *config.Config, i rpc.Invoker) http.Handler {
 		} else {
 			wr := newRequest(r)
 			wr.log(fmt.Sprintf("Start %s", wr.r.URL.Path))
-			invoke(conf, i, w, wr)
+			invoke(g, w, wr)
 		}
 
 		log.Printf(
@@ -45,64 +48,233 @@ func InvokeHandler(conf *config.Config, i rpc.Invoker) http.Handler {
 	})
 }
 
+// gateway holds the state an InvokeHandler shares across its requests
+type gateway struct {
+	conf    *config.Config
+	invoker rpc.Invoker
+
+	// routes is the route tree of the config's api events
+	routes *routeNode
+
+	// authorizers caches the responses of the config's authorizers
+	authorizers *authorizerCache
+
+	// jwtKeys caches the keys of the config's JWTAuthorizer
+	jwtKeys *jwtKeyCache
+}
+
+// newGateway builds the gateway state of a config
+func newGateway(conf *config.Config, i rpc.Invoker) *gateway {
+	return &gateway{
+		conf:        conf,
+		invoker:     i,
+		routes:      newRouteTree(conf.Events),
+		authorizers: newAuthorizerCache(),
+		jwtKeys:     &jwtKeyCache{},
+	}
+}
+
 // invoke wraps http invocation and makes it easier to deal with logging
 // of requests
-func invoke(
-	conf *config.Config,
-	i rpc.Invoker,
-	w http.ResponseWriter,
-	r *wrappedRequest,
-) {
-	f, pathParams := route(conf, r.r)
-	if f == nil {
+func invoke(g *gateway, w http.ResponseWriter, r *wrappedRequest) {
+	conf, i := g.conf, g.invoker
+	w.Header().Set("x-amzn-RequestId", r.id)
+
+	stage, stagePath := resolveStage(conf, r.r.URL.Path)
+	if stage == nil {
+		r.log("No matching stage")
+		writeGatewayError(conf, w, errMissingAuthenticationToken, nil, nil)
+		return
+	}
+	r.withStage(stage, stagePath)
+
+	if isPreflight(r.r) {
+		if cors := preflightCORS(conf, g.routes, r.r); cors != nil {
+			r.log("CORS preflight")
+			writePreflight(w, r.r, cors)
+			return
+		}
+	}
+
+	event, pathParams, allowed := g.routes.route(r.r)
+
+	// CORS headers are set up front so they're on gateway errors too, and
+	// are passed to write so they replace any the function returns
+	cors := corsHeaders(conf.CORSFor(event), r.r)
+	setHeaders(w, cors)
+
+	if event == nil && allowed != nil {
+		r.log(fmt.Sprintf("Method %s not allowed", r.r.Method))
+		writeMethodNotAllowed(conf, w, allowed)
+		return
+	} else if event == nil {
 		r.log("No matching route")
-		w.WriteHeader(http.StatusNotFound)
+		writeGatewayError(conf, w, errMissingAuthenticationToken, nil, nil)
 		return
 	}
 
-	invokeReq, prepareErr := r.prepareRequest(pathParams)
+	if gwErr, cause := authorize(g, r, event, pathParams); gwErr != nil {
+		r.errorLog(cause)
+		writeGatewayError(conf, w, gwErr, cause, nil)
+		return
+	}
+
+	f := conf.Functions[event.Target]
+
+	prepare, write := r.prepareRequest, writeInvokeResponse
+	internalErr, timeoutErr := errBadGateway, errEndpointTimeout
+	if event.PayloadVersion() == config.PayloadVersion2 {
+		prepare, write = r.prepareHTTPAPIRequest, writeHTTPAPIResponse
+		internalErr = errHTTPAPIInternalServerError
+		timeoutErr = errHTTPAPIServiceUnavailable
+	}
+
+	invokeReq, prepareErr := prepare(conf, event, pathParams)
 	if prepareErr != nil {
 		r.errorLog(prepareErr)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeGatewayError(conf, w, errInternalServerError, prepareErr, nil)
 		return
 	}
 
-	resp := &messages.InvokeResponse{}
-	invokeErr := i(f.Name, invokeReq, resp)
-	if invokeErr != nil {
+	resp, invokeErr := invokeWithTimeout(i, f.Name, invokeReq, integrationTimeout(conf))
+	if _, ok := invokeErr.(*rpc.ThrottleError); ok {
+		r.errorLog(invokeErr)
+		writeGatewayError(conf, w, errTooManyRequests, invokeErr, nil)
+		return
+	} else if invokeErr == errIntegrationTimeout {
+		r.errorLog(invokeErr)
+		writeGatewayError(conf, w, timeoutErr, invokeErr, nil)
+		return
+	} else if invokeErr != nil {
 		r.errorLog(invokeErr)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeGatewayError(conf, w, internalErr, invokeErr, nil)
 		return
 	}
 
 	if resp.Error != nil {
 		r.log(fmt.Sprintf("Invocation Error: %s", resp.Error.Message))
-		w.WriteHeader(http.StatusInternalServerError)
+		writeGatewayError(conf, w, internalErr, nil, resp.Error)
 		return
 	}
 
-	writeErr := writeInvokeResponse(w, resp)
+	writeErr := write(w, resp, cors)
 	if writeErr != nil {
-		r.errorLog(writeErr)
-		w.WriteHeader(http.StatusInternalServerError)
+		r.errorLog(fmt.Errorf("Malformed Lambda proxy response: %s", writeErr))
+		writeGatewayError(conf, w, internalErr, writeErr, nil)
 		return
 	}
 }
 
-// writes an http response based on the given InvokeResponse
+// errIntegrationTimeout is returned by invokeWithTimeout when the function
+// doesn't respond in time
+var errIntegrationTimeout = errors.New("Endpoint request timed out")
+
+// invokeWithTimeout invokes a function, giving up on its response after the
+// timeout as API Gateway does. The invocation itself carries on until the
+// function responds or hits its own timeout.
+func invokeWithTimeout(
+	i rpc.Invoker,
+	name string,
+	req *messages.InvokeRequest,
+	timeout time.Duration,
+) (*messages.InvokeResponse, error) {
+	resp := &messages.InvokeResponse{}
+	done := make(chan error, 1)
+	go func() {
+		done <- i(name, req, resp)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case invokeErr := <-done:
+		return resp, invokeErr
+	case <-timer.C:
+		return nil, errIntegrationTimeout
+	}
+}
+
+// integrationTimeout returns how long the gateway waits for functions
+func integrationTimeout(conf *config.Config) time.Duration {
+	if conf.API.IntegrationTimeout == 0 {
+		return config.DefaultIntegrationTimeout
+	}
+
+	return conf.API.IntegrationTimeout
+}
+
+// writeMethodNotAllowed responds to a request whose path matched a route but
+// whose method didn't
+func writeMethodNotAllowed(
+	conf *config.Config, w http.ResponseWriter, allowed []string,
+) {
+	if conf.API.MissingAuthenticationToken {
+		writeGatewayError(conf, w, errMissingAuthenticationToken, nil, nil)
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// writes an http response based on the given InvokeResponse, with the
+// gateway's CORS headers replacing any the function returned
 func writeInvokeResponse(
-	w http.ResponseWriter, resp *messages.InvokeResponse,
+	w http.ResponseWriter, resp *messages.InvokeResponse, cors http.Header,
 ) error {
 	var gwResp events.APIGatewayProxyResponse
 	if unmarshalErr := json.Unmarshal(resp.Payload, &gwResp); unmarshalErr != nil {
 		return unmarshalErr
 	}
 
-	for key, val := range gwResp.Headers {
-		w.Header().Add(key, val)
+	if !validStatusCode(gwResp.StatusCode) {
+		return fmt.Errorf("Invalid statusCode %d", gwResp.StatusCode)
 	}
+
+	body := []byte(gwResp.Body)
+	if gwResp.IsBase64Encoded {
+		decoded, decodeErr := base64.StdEncoding.DecodeString(gwResp.Body)
+		if decodeErr != nil {
+			return decodeErr
+		}
+		body = decoded
+	}
+
+	for key, vals := range responseHeaders(&gwResp) {
+		for _, val := range vals {
+			w.Header().Add(key, val)
+		}
+	}
+	setHeaders(w, cors)
 	w.WriteHeader(gwResp.StatusCode)
-	w.Write([]byte(gwResp.Body))
+	w.Write(body)
 
 	return nil
 }
+
+// responseHeaders merges a response's headers and multi value headers the
+// way API Gateway does, with multi value headers replacing any header of the
+// same name
+func responseHeaders(gwResp *events.APIGatewayProxyResponse) http.Header {
+	headers := make(http.Header)
+	for key, vals := range gwResp.MultiValueHeaders {
+		for _, val := range vals {
+			headers.Add(key, val)
+		}
+	}
+
+	for key, val := range gwResp.Headers {
+		if _, ok := headers[http.CanonicalHeaderKey(key)]; !ok {
+			headers.Set(key, val)
+		}
+	}
+
+	return headers
+}
+
+// validStatusCode returns true if a function's response status code can be
+// written
+func validStatusCode(status int) bool {
+	return status >= 100 && status <= 599
+}
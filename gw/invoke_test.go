@@ -8,11 +8,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda/messages"
 
 	"github.com/nalanj/ladle/config"
+	"github.com/nalanj/ladle/rpc"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,31 +23,113 @@ func TestInvoke(t *testing.T) {
 
 	tests := []struct {
 		name   string
+		method string
 		path   string
+		api    config.API
 		status int
+		allow  string
+		body   string
 	}{
 		{
-			"returns not found when no route matches",
-			"/not-found",
-			http.StatusNotFound,
+			name:   "returns missing authentication token when no route matches",
+			method: "POST",
+			path:   "/not-found",
+			status: http.StatusForbidden,
+			body:   `{"message":"Missing Authentication Token"}`,
 		},
 		{
-			"returns internal error on invoke error",
-			"/invoke-error",
-			http.StatusInternalServerError,
+			name:   "returns bad gateway on invoke error",
+			method: "POST",
+			path:   "/invoke-error",
+			status: http.StatusBadGateway,
+			body:   `{"message":"Internal server error"}`,
 		},
 		{
-			"returns success on success",
-			"/echo",
-			http.StatusOK,
+			name:   "returns bad gateway on function error",
+			method: "POST",
+			path:   "/function-error",
+			status: http.StatusBadGateway,
+			body:   `{"message":"Internal server error"}`,
+		},
+		{
+			name:   "returns function errors in verbose mode",
+			method: "POST",
+			path:   "/function-error",
+			api:    config.API{VerboseErrors: true},
+			status: http.StatusBadGateway,
+			body: `{"message":"Internal server error",` +
+				`"errorMessage":"boom","errorType":"errorString",` +
+				`"stackTrace":[{"path":"main.go","line":12,"label":"handler"}]}`,
+		},
+		{
+			name:   "returns bad gateway on a malformed response",
+			method: "POST",
+			path:   "/malformed",
+			status: http.StatusBadGateway,
+			body:   `{"message":"Internal server error"}`,
+		},
+		{
+			name:   "returns internal server error from http apis",
+			method: "POST",
+			path:   "/http-api-error",
+			status: http.StatusInternalServerError,
+			body:   `{"message":"Internal Server Error"}`,
+		},
+		{
+			name:   "returns gateway timeout when the function is slow",
+			method: "POST",
+			path:   "/slow",
+			api:    config.API{IntegrationTimeout: 10 * time.Millisecond},
+			status: http.StatusGatewayTimeout,
+			body:   `{"message":"Endpoint request timed out"}`,
+		},
+		{
+			name:   "returns too many requests when throttled",
+			method: "POST",
+			path:   "/throttled",
+			status: http.StatusTooManyRequests,
+			body:   `{"message":"Too Many Requests"}`,
+		},
+		{
+			name:   "returns success on success",
+			method: "POST",
+			path:   "/echo",
+			status: http.StatusOK,
+			body:   "OK",
+		},
+		{
+			name:   "returns success on a matching method",
+			method: "GET",
+			path:   "/methods",
+			status: http.StatusOK,
+			body:   "OK",
+		},
+		{
+			name:   "returns method not allowed on other methods",
+			method: "POST",
+			path:   "/methods",
+			status: http.StatusMethodNotAllowed,
+			allow:  "DELETE, GET",
+		},
+		{
+			name:   "returns missing authentication token on other methods",
+			method: "POST",
+			path:   "/methods",
+			api:    config.API{MissingAuthenticationToken: true},
+			status: http.StatusForbidden,
+			body:   `{"message":"Missing Authentication Token"}`,
 		},
 	}
 
-	// Test against two functions. Echo is will be running and InvokeError
-	// will not be running
+	// Test against several functions. Echo is will be running and the others
+	// fail in different ways
 	functions := map[string]*config.Function{
-		"Echo":        &config.Function{Name: "Echo", Package: "../build/echo"},
-		"InvokeError": &config.Function{Name: "InvokeError", Package: "n/a"},
+		"Echo":          &config.Function{Name: "Echo", Package: "../build/echo"},
+		"InvokeError":   &config.Function{Name: "InvokeError", Package: "n/a"},
+		"Throttled":     &config.Function{Name: "Throttled", Package: "n/a"},
+		"FunctionError": &config.Function{Name: "FunctionError", Package: "n/a"},
+		"Malformed":     &config.Function{Name: "Malformed", Package: "n/a"},
+		"Slow":          &config.Function{Name: "Slow", Package: "n/a"},
 	}
 
 	invoker := func(
@@ -65,6 +149,32 @@ func TestInvoke(t *testing.T) {
 
 			return nil
 		}
+
+		if name == "Throttled" {
+			return &rpc.ThrottleError{Name: name}
+		}
+
+		if name == "FunctionError" {
+			resp.Error = &messages.InvokeResponse_Error{
+				Message: "boom",
+				Type:    "errorString",
+				StackTrace: []*messages.InvokeResponse_Error_StackFrame{
+					{Path: "main.go", Line: 12, Label: "handler"},
+				},
+			}
+			return nil
+		}
+
+		if name == "Malformed" {
+			resp.Payload = []byte(`{"statusCode":`)
+			return nil
+		}
+
+		if name == "Slow" {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		}
+
 		return errors.New("Invoke error")
 	}
 
@@ -72,6 +182,7 @@ func TestInvoke(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			cfg := &config.Config{
+				API:       test.api,
 				Functions: functions,
 				Events: []*config.Event{
 					&config.Event{
@@ -84,22 +195,69 @@ func TestInvoke(t *testing.T) {
 						Target: "InvokeError",
 						Meta:   map[string]string{"Route": "/invoke-error"},
 					},
+					&config.Event{
+						Source: config.APISource,
+						Target: "Throttled",
+						Meta:   map[string]string{"Route": "/throttled"},
+					},
+					&config.Event{
+						Source: config.APISource,
+						Target: "FunctionError",
+						Meta:   map[string]string{"Route": "/function-error"},
+					},
+					&config.Event{
+						Source: config.APISource,
+						Target: "FunctionError",
+						Meta: map[string]string{
+							"Route":          "/http-api-error",
+							"PayloadVersion": config.PayloadVersion2,
+						},
+					},
+					&config.Event{
+						Source: config.APISource,
+						Target: "Malformed",
+						Meta:   map[string]string{"Route": "/malformed"},
+					},
+					&config.Event{
+						Source: config.APISource,
+						Target: "Slow",
+						Meta:   map[string]string{"Route": "/slow"},
+					},
+					&config.Event{
+						Source: config.APISource,
+						Target: "Echo",
+						Meta: map[string]string{
+							"Route":  "/methods",
+							"Method": "GET",
+						},
+					},
+					&config.Event{
+						Source: config.APISource,
+						Target: "Echo",
+						Meta: map[string]string{
+							"Route":  "/methods",
+							"Method": "DELETE",
+						},
+					},
 				},
 			}
 
 			w := httptest.NewRecorder()
 
 			req, reqErr := http.NewRequest(
-				"POST",
+				test.method,
 				fmt.Sprintf("https://testing.com:3030%s", test.path),
 				bytes.NewReader([]byte("testBody")),
 			)
 			assert.Nil(t, reqErr)
 			wr := newRequest(req)
 
-			invoke(cfg, invoker, w, wr)
+			invoke(newGateway(cfg, invoker), w, wr)
 
 			assert.Equal(t, test.status, w.Code)
+			assert.Equal(t, test.allow, w.Header().Get("Allow"))
+			assert.Equal(t, test.body, w.Body.String())
+			assert.Equal(t, wr.id, w.Header().Get("x-amzn-RequestId"))
 		})
 	}
 }
@@ -117,6 +275,7 @@ func TestWriteInvokeResponse(t *testing.T) {
 	writeErr := writeInvokeResponse(
 		w,
 		&messages.InvokeResponse{Payload: gwRespBytes},
+		nil,
 	)
 	assert.Nil(t, writeErr)
 
@@ -124,3 +283,67 @@ func TestWriteInvokeResponse(t *testing.T) {
 	assert.Equal(t, "test body", w.Body.String())
 	assert.Equal(t, "yes", w.Header().Get("Cool-Header"))
 }
+
+func TestWriteInvokeResponseBase64(t *testing.T) {
+	w := httptest.NewRecorder()
+	gwResp := &events.APIGatewayProxyResponse{
+		Body:            "iVBORwD/",
+		IsBase64Encoded: true,
+		StatusCode:      http.StatusOK,
+	}
+	gwRespBytes, marshalErr := json.Marshal(gwResp)
+	assert.Nil(t, marshalErr)
+
+	writeErr := writeInvokeResponse(
+		w,
+		&messages.InvokeResponse{Payload: gwRespBytes},
+		nil,
+	)
+	assert.Nil(t, writeErr)
+	assert.Equal(t, []byte{0x89, 0x50, 0x4e, 0x47, 0x00, 0xff}, w.Body.Bytes())
+}
+
+func TestResponseHeaders(t *testing.T) {
+	t.Parallel()
+
+	headers := responseHeaders(&events.APIGatewayProxyResponse{
+		Headers: map[string]string{
+			"Content-Type": "text/plain",
+			"set-cookie":   "ignored=1",
+		},
+		MultiValueHeaders: map[string][]string{
+			"Set-Cookie": {"session=abc; HttpOnly", "theme=dark"},
+		},
+	})
+
+	assert.Equal(t, []string{"text/plain"}, headers["Content-Type"])
+	assert.Equal(
+		t,
+		[]string{"session=abc; HttpOnly", "theme=dark"},
+		headers["Set-Cookie"],
+	)
+}
+
+func TestWriteInvokeResponseMultiValueHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	gwResp := &events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		MultiValueHeaders: map[string][]string{
+			"Set-Cookie": {"session=abc", "theme=dark"},
+		},
+	}
+	gwRespBytes, marshalErr := json.Marshal(gwResp)
+	assert.Nil(t, marshalErr)
+
+	writeErr := writeInvokeResponse(
+		w,
+		&messages.InvokeResponse{Payload: gwRespBytes},
+		nil,
+	)
+	assert.Nil(t, writeErr)
+	assert.Equal(
+		t,
+		[]string{"session=abc", "theme=dark"},
+		w.Result().Header["Set-Cookie"],
+	)
+}
@@ -0,0 +1,248 @@
+package gw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nalanj/ladle/config"
+	"github.com/nalanj/ladle/jwt"
+)
+
+// jwtUnauthorized returns the error HTTP API JWT authorizers respond with when
+// a token is missing or invalid
+func jwtUnauthorized(description string) *gatewayError {
+	return &gatewayError{
+		Status:  http.StatusUnauthorized,
+		Type:    "UnauthorizedException",
+		Message: "Unauthorized",
+		Header: http.Header{
+			"Www-Authenticate": {fmt.Sprintf(
+				`Bearer scope="" error="invalid_token" error_description="%s"`,
+				description,
+			)},
+		},
+	}
+}
+
+// errInsufficientScope is the error HTTP API JWT authorizers respond with when
+// a token lacks the scopes a route needs
+var errInsufficientScope = &gatewayError{
+	Status:  http.StatusForbidden,
+	Type:    "ForbiddenException",
+	Message: "Forbidden",
+	Header: http.Header{
+		"Www-Authenticate": {
+			`Bearer scope="" error="insufficient_scope" error_description="the token does not have the required scopes"`,
+		},
+	},
+}
+
+// authorizeJWT validates the bearer token of a request routed to an event
+// protected by the API's JWTAuthorizer. When the token is valid its claims
+// and scopes are stored on the request for its function's request context.
+func authorizeJWT(
+	g *gateway,
+	r *wrappedRequest,
+	event *config.Event,
+) (*gatewayError, error) {
+	auth := g.conf.API.JWTAuthorizer
+	if auth == nil {
+		return errAuthorizerConfiguration, errors.New("No JWTAuthorizer configured")
+	}
+
+	token := strings.TrimSpace(r.r.Header.Get("Authorization"))
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	if token == "" {
+		return jwtUnauthorized("the token is missing"), errors.New("Missing token")
+	}
+
+	keys, keysErr := g.jwtKeys.get(g.conf)
+	if keysErr != nil {
+		return errAuthorizerConfiguration, keysErr
+	}
+
+	claims, verifyErr := jwt.Verify(token, keys, time.Now())
+	if verifyErr != nil {
+		return jwtUnauthorized(verifyErr.Error()), verifyErr
+	}
+
+	if claims["iss"] != auth.Issuer {
+		return jwtUnauthorized("the token issuer is invalid"),
+			fmt.Errorf("Invalid issuer %v", claims["iss"])
+	}
+
+	if !audienceMatches(claims, auth.Audiences) {
+		return jwtUnauthorized("the token audience is invalid"),
+			fmt.Errorf("Invalid audience %v", claims["aud"])
+	}
+
+	scopes := tokenScopes(claims)
+	if !scopesMatch(scopes, event.Meta["AuthorizationScopes"]) {
+		return errInsufficientScope, fmt.Errorf("Insufficient scopes %v", scopes)
+	}
+
+	jwtContext := map[string]interface{}{
+		"claims": stringClaims(claims),
+		"scopes": scopes,
+	}
+	r.authorizer = map[string]interface{}{"jwt": jwtContext}
+	r.httpAPIAuthorizer = r.authorizer
+
+	return nil, nil
+}
+
+// jwtKeyCache holds the keys tokens are verified with, so they're loaded once
+// rather than on every request
+type jwtKeyCache struct {
+	mtx  sync.Mutex
+	keys *jwt.JWKS
+}
+
+// get returns the cached keys, loading them if they haven't been. Keys that
+// fail to load are retried on the next request, as the key ladle token signs
+// with may not have been created yet.
+func (c *jwtKeyCache) get(conf *config.Config) (*jwt.JWKS, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.keys == nil {
+		keys, keysErr := jwtKeys(conf)
+		if keysErr != nil {
+			return nil, keysErr
+		}
+		c.keys = keys
+	}
+
+	return c.keys, nil
+}
+
+// jwtKeys returns the keys tokens are verified with: the JWTAuthorizer's
+// inline Keys or JWKS file, or else the key ladle token signs with
+func jwtKeys(conf *config.Config) (*jwt.JWKS, error) {
+	auth := conf.API.JWTAuthorizer
+
+	if auth.Keys != nil {
+		data, marshalErr := json.Marshal(map[string]interface{}{"keys": auth.Keys})
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+
+		return jwt.ParseJWKS(data)
+	}
+
+	if auth.JWKS != "" {
+		data, readErr := ioutil.ReadFile(conf.JWKSPath())
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		return jwt.ParseJWKS(data)
+	}
+
+	key, keyErr := jwt.LoadKey(conf.TokenKeyPath())
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	jwk, jwkErr := jwt.NewJWK(key.Public(), "")
+	if jwkErr != nil {
+		return nil, jwkErr
+	}
+
+	return &jwt.JWKS{Keys: []jwt.JWK{jwk}}, nil
+}
+
+// audienceMatches returns true if the token's aud claim, or client_id claim
+// when it has no aud, is one of the audiences
+func audienceMatches(claims map[string]interface{}, audiences []string) bool {
+	tokenAudiences := []interface{}{}
+	switch aud := claims["aud"].(type) {
+	case string:
+		tokenAudiences = append(tokenAudiences, aud)
+	case []interface{}:
+		tokenAudiences = aud
+	case nil:
+		tokenAudiences = append(tokenAudiences, claims["client_id"])
+	}
+
+	for _, tokenAudience := range tokenAudiences {
+		for _, audience := range audiences {
+			if tokenAudience == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// tokenScopes returns the scopes in a token's scope or scp claim
+func tokenScopes(claims map[string]interface{}) []string {
+	var scopes []string
+
+	if scope, ok := claims["scope"].(string); ok {
+		scopes = strings.Fields(scope)
+	}
+
+	if scp, ok := claims["scp"].([]interface{}); ok {
+		for _, s := range scp {
+			if str, ok := s.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+	}
+
+	return scopes
+}
+
+// scopesMatch returns true if a route has no required scopes or the token
+// has at least one of them
+func scopesMatch(scopes []string, required string) bool {
+	if required == "" {
+		return true
+	}
+
+	for _, req := range strings.Split(required, ",") {
+		for _, scope := range scopes {
+			if scope == req {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// stringClaims converts claims to strings, as HTTP APIs pass them to
+// functions
+func stringClaims(claims map[string]interface{}) map[string]string {
+	out := make(map[string]string, len(claims))
+
+	for key, val := range claims {
+		switch val := val.(type) {
+		case string:
+			out[key] = val
+		case json.Number:
+			out[key] = val.String()
+		case []interface{}:
+			parts := []string{}
+			for _, part := range val {
+				parts = append(parts, fmt.Sprint(part))
+			}
+			out[key] = "[" + strings.Join(parts, " ") + "]"
+		default:
+			data, _ := json.Marshal(val)
+			out[key] = string(data)
+		}
+	}
+
+	return out
+}
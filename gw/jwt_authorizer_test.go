@@ -0,0 +1,225 @@
+package gw
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/nalanj/ladle/config"
+	"github.com/nalanj/ladle/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizeJWT(t *testing.T) {
+	t.Parallel()
+
+	key, keyErr := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, keyErr)
+	jwk, jwkErr := jwt.NewJWK(key.Public(), "test-key")
+	assert.Nil(t, jwkErr)
+
+	conf := &config.Config{
+		API: config.API{
+			JWTAuthorizer: &config.JWTAuthorizer{
+				Issuer:    "https://issuer.example.com",
+				Audiences: []string{"web"},
+				Keys: []map[string]string{
+					{"kty": jwk.Kty, "kid": jwk.Kid, "n": jwk.N, "e": jwk.E},
+				},
+			},
+		},
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		header string
+		scopes string
+		status int
+	}{
+		{
+			name: "accepts a valid token",
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+				"aud": "web",
+				"sub": "user-1",
+				"exp": exp,
+			},
+		},
+		{
+			name: "accepts a client_id audience",
+			claims: map[string]interface{}{
+				"iss":       "https://issuer.example.com",
+				"client_id": "web",
+				"sub":       "user-1",
+				"exp":       exp,
+			},
+		},
+		{
+			name: "rejects a token without an expiration",
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+				"aud": "web",
+				"sub": "user-1",
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "rejects a missing token",
+			header: " ",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "rejects a malformed token",
+			header: "Bearer not-a-token",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "rejects another issuer",
+			claims: map[string]interface{}{
+				"iss": "https://other.example.com",
+				"aud": "web",
+				"sub": "user-1",
+				"exp": exp,
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "rejects another audience",
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+				"aud": []string{"mobile"},
+				"sub": "user-1",
+				"exp": exp,
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "rejects an expired token",
+			claims: map[string]interface{}{
+				"iss": "https://issuer.example.com",
+				"aud": "web",
+				"sub": "user-1",
+				"exp": time.Now().Add(-time.Minute).Unix(),
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "accepts a token with a required scope",
+			claims: map[string]interface{}{
+				"iss":   "https://issuer.example.com",
+				"aud":   "web",
+				"sub":   "user-1",
+				"scope": "read write",
+				"exp":   exp,
+			},
+			scopes: "admin,write",
+		},
+		{
+			name: "forbids a token without a required scope",
+			claims: map[string]interface{}{
+				"iss":   "https://issuer.example.com",
+				"aud":   "web",
+				"sub":   "user-1",
+				"scope": "read",
+				"exp":   exp,
+			},
+			scopes: "write",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			header := test.header
+			if test.claims != nil {
+				token, signErr := jwt.Sign(test.claims, key, jwk.Kid)
+				assert.Nil(t, signErr)
+				header = "Bearer " + token
+			}
+
+			req, reqErr := http.NewRequest(
+				"GET",
+				"https://testing.com/private",
+				bytes.NewReader(nil),
+			)
+			assert.Nil(t, reqErr)
+			req.Header.Set("Authorization", header)
+
+			event := &config.Event{
+				Source: config.APISource,
+				Meta: map[string]string{
+					"Route":               "/private",
+					"Authorizer":          config.JWTAuthorizerName,
+					"AuthorizationScopes": test.scopes,
+				},
+			}
+
+			wr := newRequest(req)
+			gwErr, _ := authorize(newGateway(conf, nil), wr, event, nil)
+
+			if test.status != 0 {
+				assert.NotNil(t, gwErr)
+				assert.Equal(t, test.status, gwErr.Status)
+				assert.NotEmpty(t, gwErr.Header.Get("Www-Authenticate"))
+				return
+			}
+
+			assert.Nil(t, gwErr)
+			jwtContext := wr.authorizer["jwt"].(map[string]interface{})
+			claims := jwtContext["claims"].(map[string]string)
+			assert.Equal(t, "user-1", claims["sub"])
+			assert.Equal(t, wr.authorizer, wr.httpAPIAuthorizer)
+		})
+	}
+}
+
+func TestJWTKeyCache(t *testing.T) {
+	t.Parallel()
+
+	dir, dirErr := ioutil.TempDir("", "ladle-jwks")
+	assert.Nil(t, dirErr)
+	defer os.RemoveAll(dir)
+
+	conf := &config.Config{
+		Path: path.Join(dir, "ladle.confl"),
+		API: config.API{
+			JWTAuthorizer: &config.JWTAuthorizer{JWKS: "jwks.json"},
+		},
+	}
+	cache := &jwtKeyCache{}
+
+	// keys that fail to load are retried
+	_, missingErr := cache.get(conf)
+	assert.NotNil(t, missingErr)
+
+	key, keyErr := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, keyErr)
+	jwk, jwkErr := jwt.NewJWK(key.Public(), "test-key")
+	assert.Nil(t, jwkErr)
+	data, marshalErr := json.Marshal(jwt.JWKS{Keys: []jwt.JWK{jwk}})
+	assert.Nil(t, marshalErr)
+	assert.Nil(t, ioutil.WriteFile(conf.JWKSPath(), data, 0644))
+
+	keys, getErr := cache.get(conf)
+	assert.Nil(t, getErr)
+	assert.Equal(t, "test-key", keys.Keys[0].Kid)
+
+	// loaded keys aren't read again
+	assert.Nil(t, os.Remove(conf.JWKSPath()))
+	cached, cachedErr := cache.get(conf)
+	assert.Nil(t, cachedErr)
+	assert.True(t, keys == cached)
+}
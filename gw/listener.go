@@ -1,15 +1,47 @@
 package gw
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/nalanj/ladle/config"
 	"github.com/nalanj/ladle/rpc"
 )
 
-// Listener starts up a listener that simulates api gateway
-func Listener(conf *config.Config, i rpc.Invoker) {
+const (
+	// shutdownTimeout is how long in-flight requests have to finish when the
+	// listener shuts down
+	shutdownTimeout = 10 * time.Second
+)
+
+// Listener starts up a listener that simulates api gateway. When ctx is done
+// the listener stops accepting requests and waits for in-flight requests to
+// finish before returning.
+func Listener(ctx context.Context, conf *config.Config, i rpc.Invoker) error {
+	srv := &http.Server{Addr: conf.HTTPAddress, Handler: InvokeHandler(conf, i)}
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- srv.ListenAndServe()
+	}()
+
 	log.Printf("HTTP: Listening on %s\n", conf.HTTPAddress)
-	http.ListenAndServe(conf.HTTPAddress, InvokeHandler(conf, i))
+
+	select {
+	case err := <-listenErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("HTTP: Shutting down\n")
+
+	shutdownCtx, cancel := context.WithTimeout(
+		context.Background(),
+		shutdownTimeout,
+	)
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
 }
@@ -0,0 +1,48 @@
+package gw
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nalanj/ladle/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListener(t *testing.T) {
+	t.Parallel()
+
+	t.Run("shuts down when the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		listenErr := make(chan error, 1)
+		go func() {
+			listenErr <- Listener(
+				ctx,
+				&config.Config{HTTPAddress: "localhost:0"},
+				nil,
+			)
+		}()
+
+		cancel()
+
+		select {
+		case err := <-listenErr:
+			assert.Nil(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("listener didn't shut down")
+		}
+	})
+
+	t.Run("returns an error if it can't listen", func(t *testing.T) {
+		t.Parallel()
+
+		err := Listener(
+			context.Background(),
+			&config.Config{HTTPAddress: "invalid-address"},
+			nil,
+		)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,124 @@
+package gw
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nalanj/ladle/config"
+)
+
+// requestTimeFormat is the format of the request time in request contexts
+const requestTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// proxyRequest is an APIGatewayProxyRequest with the full request context
+// API Gateway sends
+type proxyRequest struct {
+	events.APIGatewayProxyRequest
+	RequestContext proxyRequestContext `json:"requestContext"`
+}
+
+// proxyRequestContext adds the request context fields API Gateway sends that
+// APIGatewayProxyRequestContext lacks
+type proxyRequestContext struct {
+	events.APIGatewayProxyRequestContext
+	DomainName        string `json:"domainName"`
+	DomainPrefix      string `json:"domainPrefix"`
+	ExtendedRequestID string `json:"extendedRequestId"`
+	Path              string `json:"path"`
+	Protocol          string `json:"protocol"`
+	RequestTime       string `json:"requestTime"`
+	RequestTimeEpoch  int64  `json:"requestTimeEpoch"`
+}
+
+// newProxyRequestContext builds the request context for a request routed to
+// the event
+func newProxyRequestContext(
+	conf *config.Config,
+	r *wrappedRequest,
+	event *config.Event,
+	now time.Time,
+) proxyRequestContext {
+	stage := r.stageName(conf)
+
+	return proxyRequestContext{
+		APIGatewayProxyRequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:  accountID(conf),
+			ResourceID: resourceID(event.Meta["Route"]),
+			Stage:      stage,
+			RequestID:  r.id,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  sourceIP(r.r),
+				UserAgent: r.r.UserAgent(),
+			},
+			ResourcePath: event.Meta["Route"],
+			Authorizer:   r.authorizer,
+			HTTPMethod:   r.r.Method,
+			APIID:        apiID(conf),
+		},
+		DomainName:        r.r.Host,
+		DomainPrefix:      domainPrefix(r.r.Host),
+		ExtendedRequestID: r.id,
+		Path:              "/" + stage + r.r.URL.Path,
+		Protocol:          r.r.Proto,
+		RequestTime:       now.UTC().Format(requestTimeFormat),
+		RequestTimeEpoch:  now.UnixNano() / int64(time.Millisecond),
+	}
+}
+
+// accountID returns the account id requests appear to be made under
+func accountID(conf *config.Config) string {
+	if conf.AccountID == "" {
+		return config.DefaultAccountID
+	}
+
+	return conf.AccountID
+}
+
+// apiID returns the id of the api requests appear to be made to
+func apiID(conf *config.Config) string {
+	if conf.API.ID == "" {
+		return config.DefaultAPIID
+	}
+
+	return conf.API.ID
+}
+
+// apiStage returns the stage requests appear to be made to
+func apiStage(conf *config.Config) string {
+	if conf.API.Stage == "" {
+		return config.DefaultStage
+	}
+
+	return conf.API.Stage
+}
+
+// resourceID returns a stable id for a route, like the resource ids API
+// Gateway assigns
+func resourceID(route string) string {
+	sum := sha1.Sum([]byte(route))
+	return hex.EncodeToString(sum[:])[:6]
+}
+
+// domainPrefix returns the first label of a request's host
+func domainPrefix(host string) string {
+	if h, _, splitErr := net.SplitHostPort(host); splitErr == nil {
+		host = h
+	}
+
+	return strings.SplitN(host, ".", 2)[0]
+}
+
+// sourceIP returns the ip address a request came from
+func sourceIP(r *http.Request) string {
+	host, _, splitErr := net.SplitHostPort(r.RemoteAddr)
+	if splitErr != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
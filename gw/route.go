@@ -7,34 +7,15 @@ import (
 	"github.com/nalanj/ladle/config"
 )
 
-// route converts a request to its corresponding function
-func route(conf *config.Config, r *http.Request) (*config.Function, map[string]string) {
-	for _, event := range conf.Events {
-		pathParams, ok := routeMatch(r, event)
-		if ok {
-			return conf.Functions[event.Target], pathParams
-		}
-	}
-
-	return nil, nil
-}
-
-// routeMatch tests if a route matches and returns path parts if it does
+// routeMatch tests if a route's path matches and returns path parts if it does
 func routeMatch(r *http.Request, event *config.Event) (map[string]string, bool) {
 
 	if event.Source != config.APISource {
 		return nil, false
 	}
 
-	reqParts := strings.Split(r.URL.Path, "/")
-	if reqParts[0] == "" {
-		reqParts = reqParts[1:]
-	}
-
-	routeParts := strings.Split(event.Meta["Route"], "/")
-	if routeParts[0] == "" {
-		routeParts = routeParts[1:]
-	}
+	reqParts := splitPath(r.URL.Path)
+	routeParts := splitPath(event.Meta["Route"])
 
 	pathParams := make(map[string]string)
 
@@ -47,6 +28,16 @@ func routeMatch(r *http.Request, event *config.Event) (map[string]string, bool)
 		reqPart := reqParts[i]
 		routePart := routeParts[i]
 
+		if isGreedyPart(routePart) {
+			rest := strings.Join(reqParts[i:], "/")
+			if rest == "" {
+				return nil, false
+			}
+
+			pathParams[routePart[1:len(routePart)-2]] = rest
+			return pathParams, true
+		}
+
 		if strings.HasPrefix(routePart, "{") && strings.HasSuffix(routePart, "}") {
 			pathParams[routePart[1:len(routePart)-1]] = reqPart
 		} else {
@@ -63,3 +54,9 @@ func routeMatch(r *http.Request, event *config.Event) (map[string]string, bool)
 
 	return pathParams, true
 }
+
+// isGreedyPart returns true if a route part is a greedy {name+} part, which
+// matches the rest of the path
+func isGreedyPart(part string) bool {
+	return strings.HasPrefix(part, "{") && strings.HasSuffix(part, "+}")
+}
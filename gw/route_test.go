@@ -70,6 +70,33 @@ func TestRouteMatch(t *testing.T) {
 			false,
 			nil,
 		},
+		{
+			"matches a greedy param",
+			&config.Event{
+				Source: config.APISource,
+				Meta:   map[string]string{"Route": "/{proxy+}"},
+			},
+			true,
+			map[string]string{"proxy": "test/function"},
+		},
+		{
+			"matches a greedy param after a literal",
+			&config.Event{
+				Source: config.APISource,
+				Meta:   map[string]string{"Route": "/test/{rest+}"},
+			},
+			true,
+			map[string]string{"rest": "function"},
+		},
+		{
+			"misses a greedy param with nothing to match",
+			&config.Event{
+				Source: config.APISource,
+				Meta:   map[string]string{"Route": "/test/function/{rest+}"},
+			},
+			false,
+			nil,
+		},
 		{
 			"misses on non API source",
 			&config.Event{
@@ -92,3 +119,113 @@ func TestRouteMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestRoute(t *testing.T) {
+	t.Parallel()
+
+	event := func(target, route, method string) *config.Event {
+		return &config.Event{
+			Source: config.APISource,
+			Target: target,
+			Meta:   map[string]string{"Route": route, "Method": method},
+		}
+	}
+
+	conf := &config.Config{
+		Events: []*config.Event{
+			event("Proxy", "/{proxy+}", ""),
+			event("Item", "/{id}", ""),
+			event("Root", "/", ""),
+			event("Health", "/health", ""),
+			event("AnyUsers", "/users", "ANY"),
+			event("GetUsers", "/users", "GET"),
+			event("User", "/users/{id}", "GET,DELETE"),
+			event("Me", "/users/me", "GET"),
+		},
+	}
+	routes := newRouteTree(conf.Events)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		target  string
+		params  map[string]string
+		allowed []string
+	}{
+		{
+			name:   "matches the root",
+			method: "GET",
+			path:   "/",
+			target: "Root",
+			params: map[string]string{},
+		},
+		{
+			name:   "prefers a literal over a param",
+			method: "GET",
+			path:   "/health",
+			target: "Health",
+			params: map[string]string{},
+		},
+		{
+			name:   "prefers a param over a catch-all",
+			method: "GET",
+			path:   "/12",
+			target: "Item",
+			params: map[string]string{"id": "12"},
+		},
+		{
+			name:   "falls back to a catch-all",
+			method: "GET",
+			path:   "/users/12/posts",
+			target: "Proxy",
+			params: map[string]string{"proxy": "users/12/posts"},
+		},
+		{
+			name:   "prefers a named method over any",
+			method: "GET",
+			path:   "/users",
+			target: "GetUsers",
+			params: map[string]string{},
+		},
+		{
+			name:   "falls back to any method",
+			method: "POST",
+			path:   "/users",
+			target: "AnyUsers",
+			params: map[string]string{},
+		},
+		{
+			name:   "matches a param",
+			method: "DELETE",
+			path:   "/users/12",
+			target: "User",
+			params: map[string]string{"id": "12"},
+		},
+		{
+			name:    "returns allowed methods on a method miss",
+			method:  "DELETE",
+			path:    "/users/me",
+			allowed: []string{"GET"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, reqErr := http.NewRequest(test.method, test.path, nil)
+			assert.Nil(t, reqErr)
+
+			event, params, allowed := routes.route(req)
+			if test.target == "" {
+				assert.Nil(t, event)
+			} else {
+				assert.Equal(t, test.target, event.Target)
+			}
+			assert.Equal(t, test.params, params)
+			assert.Equal(t, test.allowed, allowed)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package gw
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/nalanj/ladle/config"
+)
+
+// routeNode is a node in a tree of api routes, with one level for each part
+// of a route. Requests are resolved against the tree the way API Gateway
+// resolves them, preferring literal parts over {name} parts over greedy
+// {name+} parts regardless of the order routes are configured in.
+type routeNode struct {
+	// literals are the child nodes for literal route parts
+	literals map[string]*routeNode
+
+	// variable is the child node for {name} route parts
+	variable *routeNode
+
+	// greedy holds the events for routes ending in a {name+} part
+	greedy []*config.Event
+
+	// events holds the events for routes ending at this node
+	events []*config.Event
+}
+
+// newRouteTree builds a route tree from the api events in events
+func newRouteTree(events []*config.Event) *routeNode {
+	root := &routeNode{}
+
+	for _, event := range events {
+		if event.Source != config.APISource {
+			continue
+		}
+
+		node := root
+		parts := splitPath(event.Meta["Route"])
+		for i, part := range parts {
+			if isGreedyPart(part) && i == len(parts)-1 {
+				node.greedy = append(node.greedy, event)
+				node = nil
+				break
+			}
+
+			node = node.child(part)
+		}
+
+		if node != nil {
+			node.events = append(node.events, event)
+		}
+	}
+
+	return root
+}
+
+// child returns the child node for a route part, creating it if needed
+func (n *routeNode) child(part string) *routeNode {
+	if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+		if n.variable == nil {
+			n.variable = &routeNode{}
+		}
+
+		return n.variable
+	}
+
+	if n.literals == nil {
+		n.literals = make(map[string]*routeNode)
+	}
+	if n.literals[part] == nil {
+		n.literals[part] = &routeNode{}
+	}
+
+	return n.literals[part]
+}
+
+// resolve finds the most specific events whose route matches the path parts
+func (n *routeNode) resolve(parts []string) []*config.Event {
+	if len(parts) == 0 {
+		return n.events
+	}
+
+	if child, ok := n.literals[parts[0]]; ok {
+		if events := child.resolve(parts[1:]); len(events) > 0 {
+			return events
+		}
+	}
+
+	if n.variable != nil {
+		if events := n.variable.resolve(parts[1:]); len(events) > 0 {
+			return events
+		}
+	}
+
+	if strings.Join(parts, "/") != "" {
+		return n.greedy
+	}
+
+	return nil
+}
+
+// route finds the event for a request along with its path parameters. When
+// the request's path matches a route but its method doesn't, the methods the
+// route allows are returned instead.
+func (n *routeNode) route(
+	r *http.Request,
+) (*config.Event, map[string]string, []string) {
+	events := n.resolve(splitPath(r.URL.Path))
+	if len(events) == 0 {
+		return nil, nil, nil
+	}
+
+	// an event naming the method is preferred over one matching any method
+	var match, anyMatch *config.Event
+	allowed := make(map[string]bool)
+	for _, event := range events {
+		methods := event.Methods()
+		if methods == nil && anyMatch == nil {
+			anyMatch = event
+		}
+
+		for _, method := range methods {
+			if method == r.Method && match == nil {
+				match = event
+			}
+			allowed[method] = true
+		}
+	}
+
+	if match == nil {
+		match = anyMatch
+	}
+
+	if match == nil {
+		methods := []string{}
+		for method := range allowed {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		return nil, nil, methods
+	}
+
+	pathParams, _ := routeMatch(r, match)
+	return match, pathParams, nil
+}
+
+// splitPath splits a request path or route into its parts
+func splitPath(path string) []string {
+	parts := strings.Split(path, "/")
+	if parts[0] == "" {
+		parts = parts[1:]
+	}
+
+	return parts
+}
@@ -0,0 +1,87 @@
+package gw
+
+import (
+	"strings"
+
+	"github.com/nalanj/ladle/config"
+)
+
+// resolveStage returns the stage a request path is made to and the path
+// within the stage. Paths prefixed with a declared stage's name are made to
+// that stage, and others to the stage served at the root. Nil is returned
+// when no stage serves the path.
+func resolveStage(conf *config.Config, path string) (*config.Stage, string) {
+	if len(conf.API.Stages) == 0 {
+		return &config.Stage{Name: apiStage(conf)}, path
+	}
+
+	trimmed := strings.TrimPrefix(path, "/")
+	name, rest := trimmed, "/"
+	if slash := strings.Index(trimmed, "/"); slash >= 0 {
+		name, rest = trimmed[:slash], trimmed[slash:]
+	}
+
+	if stage, ok := conf.API.Stages[name]; ok {
+		return stage, rest
+	}
+
+	if conf.API.Stage == "" {
+		return nil, path
+	}
+
+	if stage, ok := conf.API.Stages[conf.API.Stage]; ok {
+		return stage, path
+	}
+
+	return &config.Stage{Name: conf.API.Stage}, path
+}
+
+// withStage records the stage of the request and strips the stage's prefix
+// from the request's path, so it's routed by the path within the stage
+func (r *wrappedRequest) withStage(stage *config.Stage, path string) {
+	r.basePath = strings.TrimSuffix(r.r.URL.Path, path)
+	r.stage = stage
+
+	if r.basePath == "" {
+		return
+	}
+
+	u := *r.r.URL
+	u.Path = path
+	u.RawPath = ""
+
+	req := *r.r
+	req.URL = &u
+	r.r = &req
+}
+
+// stageName returns the name of the stage the request is made to
+func (r *wrappedRequest) stageName(conf *config.Config) string {
+	if r.stage == nil {
+		return apiStage(conf)
+	}
+
+	return r.stage.Name
+}
+
+// stageVariables returns the variables of the stage the request is made to,
+// or nil if it has none
+func (r *wrappedRequest) stageVariables() map[string]string {
+	if r.stage == nil || len(r.stage.Variables) == 0 {
+		return nil
+	}
+
+	return r.stage.Variables
+}
+
+// rawPath returns the escaped path of the request as it was made, including
+// any stage prefix
+func (r *wrappedRequest) rawPath() string {
+	return r.basePath + r.r.URL.EscapedPath()
+}
+
+// requestPath returns the path of the request as it was made, including any
+// stage prefix
+func (r *wrappedRequest) requestPath() string {
+	return r.basePath + r.r.URL.Path
+}
@@ -0,0 +1,187 @@
+package gw
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/nalanj/ladle/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveStage(t *testing.T) {
+	t.Parallel()
+
+	dev := &config.Stage{Name: "dev", Variables: map[string]string{"TABLE": "dev"}}
+	v1 := &config.Stage{Name: "v1"}
+	stages := map[string]*config.Stage{"dev": dev, "v1": v1}
+
+	tests := []struct {
+		name      string
+		api       config.API
+		path      string
+		wantStage *config.Stage
+		wantPath  string
+	}{
+		{
+			"the api's stage without stages",
+			config.API{Stage: "test"},
+			"/users/1",
+			&config.Stage{Name: "test"},
+			"/users/1",
+		},
+		{
+			"a declared stage's prefix",
+			config.API{Stages: stages},
+			"/dev/users/1",
+			dev,
+			"/users/1",
+		},
+		{
+			"a declared stage's root",
+			config.API{Stages: stages},
+			"/v1",
+			v1,
+			"/",
+		},
+		{
+			"nil at the root without a root stage",
+			config.API{Stages: stages},
+			"/users/1",
+			nil,
+			"/users/1",
+		},
+		{
+			"the root stage",
+			config.API{Stage: "dev", Stages: stages},
+			"/users/1",
+			dev,
+			"/users/1",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			stage, path := resolveStage(&config.Config{API: test.api}, test.path)
+			assert.Equal(t, test.wantStage, stage)
+			assert.Equal(t, test.wantPath, path)
+		})
+	}
+}
+
+func TestInvokeStage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		path           string
+		payloadVersion string
+		status         int
+		check          func(t *testing.T, payload []byte)
+	}{
+		{
+			name:   "routes a stage's requests without its prefix",
+			path:   "/dev/users/1",
+			status: http.StatusOK,
+			check: func(t *testing.T, payload []byte) {
+				gwR := &proxyRequest{}
+				assert.Nil(t, json.Unmarshal(payload, gwR))
+				assert.Equal(t, "/users/1", gwR.Path)
+				assert.Equal(t, map[string]string{"TABLE": "dev"}, gwR.StageVariables)
+				assert.Equal(t, "dev", gwR.RequestContext.Stage)
+				assert.Equal(t, "/dev/users/1", gwR.RequestContext.Path)
+			},
+		},
+		{
+			name:           "routes a stage's http api requests",
+			path:           "/dev/users/1",
+			payloadVersion: config.PayloadVersion2,
+			status:         http.StatusOK,
+			check: func(t *testing.T, payload []byte) {
+				gwR := &httpAPIRequest{}
+				assert.Nil(t, json.Unmarshal(payload, gwR))
+				assert.Equal(t, "/dev/users/1", gwR.RawPath)
+				assert.Equal(t, "/dev/users/1", gwR.RequestContext.HTTP.Path)
+				assert.Equal(t, map[string]string{"TABLE": "dev"}, gwR.StageVariables)
+				assert.Equal(t, "dev", gwR.RequestContext.Stage)
+			},
+		},
+		{
+			name:   "routes the root stage's requests",
+			path:   "/users/1",
+			status: http.StatusOK,
+			check: func(t *testing.T, payload []byte) {
+				gwR := &proxyRequest{}
+				assert.Nil(t, json.Unmarshal(payload, gwR))
+				assert.Equal(t, "/users/1", gwR.Path)
+				assert.Nil(t, gwR.StageVariables)
+				assert.Equal(t, "v1", gwR.RequestContext.Stage)
+			},
+		},
+		{
+			name:   "returns missing authentication token for unknown stages",
+			path:   "/prod/users/1",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config.Config{
+				API: config.API{
+					Stage: "v1",
+					Stages: map[string]*config.Stage{
+						"dev": &config.Stage{
+							Name:      "dev",
+							Variables: map[string]string{"TABLE": "dev"},
+						},
+						"v1": &config.Stage{Name: "v1"},
+					},
+				},
+				Functions: map[string]*config.Function{
+					"Users": &config.Function{Name: "Users"},
+				},
+				Events: []*config.Event{
+					&config.Event{
+						Source: config.APISource,
+						Target: "Users",
+						Meta: map[string]string{
+							"Route":          "/users/{id}",
+							"PayloadVersion": test.payloadVersion,
+						},
+					},
+				},
+			}
+
+			var payload []byte
+			invoker := func(
+				name string,
+				req *messages.InvokeRequest,
+				resp *messages.InvokeResponse,
+			) error {
+				payload = req.Payload
+				resp.Payload = []byte(`{"statusCode":200}`)
+				return nil
+			}
+
+			req := httptest.NewRequest("GET", test.path, bytes.NewReader(nil))
+			w := httptest.NewRecorder()
+
+			invoke(newGateway(cfg, invoker), w, newRequest(req))
+
+			assert.Equal(t, test.status, w.Code)
+			if test.check != nil {
+				test.check(t, payload)
+			}
+		})
+	}
+}
@@ -1,21 +1,37 @@
 package gw
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda/messages"
 	"github.com/gofrs/uuid"
+	"github.com/nalanj/ladle/config"
 )
 
 // wrappedRequest wraps an http request with a struct
 type wrappedRequest struct {
 	id string
 	r  *http.Request
+
+	// stage is the stage the request is made to, and basePath the stage
+	// prefix stripped from its path
+	stage    *config.Stage
+	basePath string
+
+	// authorizer is the request context's authorizer, set when the request
+	// has been authorized
+	authorizer map[string]interface{}
+
+	// httpAPIAuthorizer is the authorizer in the format of HTTP API request
+	// contexts
+	httpAPIAuthorizer map[string]interface{}
 }
 
 // newRequest initializes a new wrapped request
@@ -36,8 +52,11 @@ func (r *wrappedRequest) errorLog(err error) {
 	r.log(fmt.Sprintf("Error: %s", err))
 }
 
-// prepareRequest converts an http.Request into an InvokeRequest
+// prepareRequest converts an http.Request into an InvokeRequest for the event
+// it was routed to
 func (r *wrappedRequest) prepareRequest(
+	conf *config.Config,
+	event *config.Event,
 	pathParams map[string]string,
 ) (*messages.InvokeRequest, error) {
 	body, bodyErr := ioutil.ReadAll(r.r.Body)
@@ -50,17 +69,29 @@ func (r *wrappedRequest) prepareRequest(
 		headers[k] = v[0]
 	}
 
-	gwR := events.APIGatewayProxyRequest{
-		Path:              r.r.URL.Path,
-		PathParameters:    pathParams,
-		HTTPMethod:        r.r.Method,
-		Headers:           headers,
-		MultiValueHeaders: r.r.Header,
-		Body:              string(body),
-		IsBase64Encoded:   false,
-		RequestContext: events.APIGatewayProxyRequestContext{
-			RequestID: r.id,
+	query, multiValueQuery := queryParameters(r.r)
+
+	bodyString := string(body)
+	isBase64Encoded := conf.API.IsBinaryMediaType(r.r.Header.Get("Content-Type"))
+	if isBase64Encoded {
+		bodyString = base64.StdEncoding.EncodeToString(body)
+	}
+
+	gwR := proxyRequest{
+		APIGatewayProxyRequest: events.APIGatewayProxyRequest{
+			Resource:                        event.Meta["Route"],
+			Path:                            r.r.URL.Path,
+			PathParameters:                  pathParams,
+			StageVariables:                  r.stageVariables(),
+			HTTPMethod:                      r.r.Method,
+			Headers:                         headers,
+			MultiValueHeaders:               r.r.Header,
+			QueryStringParameters:           query,
+			MultiValueQueryStringParameters: multiValueQuery,
+			Body:                            bodyString,
+			IsBase64Encoded:                 isBase64Encoded,
 		},
+		RequestContext: newProxyRequestContext(conf, r, event, time.Now()),
 	}
 
 	payload, marshalErr := json.Marshal(gwR)
@@ -68,5 +99,26 @@ func (r *wrappedRequest) prepareRequest(
 		return nil, marshalErr
 	}
 
-	return &messages.InvokeRequest{RequestId: r.id, Payload: payload}, nil
+	return &messages.InvokeRequest{
+		RequestId:    r.id,
+		XAmznTraceId: r.r.Header.Get("X-Amzn-Trace-Id"),
+		Payload:      payload,
+	}, nil
+}
+
+// queryParameters returns the single and multi value query string parameters
+// for the request. Like API Gateway, both are nil when the request has no
+// query string and the single value map holds the last value given for a key.
+func queryParameters(r *http.Request) (map[string]string, map[string][]string) {
+	values := r.URL.Query()
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	query := make(map[string]string, len(values))
+	for k, v := range values {
+		query[k] = v[len(v)-1]
+	}
+
+	return query, values
 }
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/nalanj/ladle/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,22 +22,52 @@ func TestPrepareRequest(t *testing.T) {
 	)
 	req.Header.Add("Rando-Header", "Value1")
 	req.Header.Add("Rando-Header", "Value2")
+	req.Header.Add("X-Amzn-Trace-Id", "Root=1-5759e988-bd862e3fe1be46a994272793")
+	req.Header.Set("User-Agent", "ladle-test")
+	req.RemoteAddr = "127.0.0.1:45678"
 	assert.Nil(t, reqErr)
 
 	wr := newRequest(req)
 	pathParams := map[string]string{"param": "payload"}
-	ri, prepErr := wr.prepareRequest(pathParams)
+	event := &config.Event{
+		Source: config.APISource,
+		Meta:   map[string]string{"Route": "/{param}/function"},
+	}
+	conf := &config.Config{
+		AccountID: "000000000000",
+		API:       config.API{ID: "abcdef1234", Stage: "dev"},
+	}
+	ri, prepErr := wr.prepareRequest(conf, event, pathParams)
 	assert.Nil(t, prepErr)
+	assert.Equal(t, wr.id, ri.RequestId)
+	assert.Equal(t, "Root=1-5759e988-bd862e3fe1be46a994272793", ri.XAmznTraceId)
 
-	gwR := &events.APIGatewayProxyRequest{}
+	gwR := &proxyRequest{}
 	unmarshalErr := json.Unmarshal(ri.Payload, gwR)
 	assert.Nil(t, unmarshalErr)
 
+	assert.Equal(t, "/{param}/function", gwR.Resource)
 	assert.Equal(t, "/test/function", gwR.Path)
 	assert.Equal(t, pathParams, gwR.PathParameters)
 	assert.Equal(t, "POST", gwR.HTTPMethod)
 	assert.Equal(t, "testBody", gwR.Body)
-	assert.Equal(t, wr.id, gwR.RequestContext.RequestID)
+	assert.False(t, gwR.IsBase64Encoded)
+	ctx := gwR.RequestContext
+	assert.Equal(t, wr.id, ctx.RequestID)
+	assert.Equal(t, "000000000000", ctx.AccountID)
+	assert.Equal(t, "abcdef1234", ctx.APIID)
+	assert.Equal(t, "dev", ctx.Stage)
+	assert.Equal(t, "/{param}/function", ctx.ResourcePath)
+	assert.Len(t, ctx.ResourceID, 6)
+	assert.Equal(t, "POST", ctx.HTTPMethod)
+	assert.Equal(t, "127.0.0.1", ctx.Identity.SourceIP)
+	assert.Equal(t, "ladle-test", ctx.Identity.UserAgent)
+	assert.Equal(t, "/dev/test/function", ctx.Path)
+	assert.Equal(t, "testing.com:3030", ctx.DomainName)
+	assert.Equal(t, "testing", ctx.DomainPrefix)
+	assert.Equal(t, "HTTP/1.1", ctx.Protocol)
+	assert.NotEmpty(t, ctx.RequestTime)
+	assert.NotZero(t, ctx.RequestTimeEpoch)
 	assert.Equal(t, "Value1", gwR.Headers["Rando-Header"])
 	assert.Equal(
 		t,
@@ -43,3 +75,149 @@ func TestPrepareRequest(t *testing.T) {
 		gwR.MultiValueHeaders["Rando-Header"],
 	)
 }
+
+// TestPrepareRequestTime isn't parallel since it changes the local time zone
+func TestPrepareRequestTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { time.Local = local }()
+
+	req, reqErr := http.NewRequest(
+		"GET",
+		"https://testing.com/users",
+		bytes.NewReader(nil),
+	)
+	assert.Nil(t, reqErr)
+
+	event := &config.Event{
+		Source: config.APISource,
+		Meta:   map[string]string{"Route": "/users"},
+	}
+
+	ri, prepErr := newRequest(req).prepareRequest(&config.Config{}, event, nil)
+	assert.Nil(t, prepErr)
+
+	gwR := &proxyRequest{}
+	assert.Nil(t, json.Unmarshal(ri.Payload, gwR))
+	assert.Regexp(t, ` \+0000$`, gwR.RequestContext.RequestTime)
+}
+
+func TestPrepareRequestQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		url        string
+		query      map[string]string
+		multiQuery map[string][]string
+	}{
+		{
+			name: "no query",
+			url:  "https://testing.com/test",
+		},
+		{
+			name: "empty query",
+			url:  "https://testing.com/test?",
+		},
+		{
+			name:       "single values",
+			url:        "https://testing.com/test?page=2&sort=name",
+			query:      map[string]string{"page": "2", "sort": "name"},
+			multiQuery: map[string][]string{"page": {"2"}, "sort": {"name"}},
+		},
+		{
+			name:       "repeated key",
+			url:        "https://testing.com/test?id=1&id=2",
+			query:      map[string]string{"id": "2"},
+			multiQuery: map[string][]string{"id": {"1", "2"}},
+		},
+		{
+			name:       "empty and encoded values",
+			url:        "https://testing.com/test?flag&q=a%20b",
+			query:      map[string]string{"flag": "", "q": "a b"},
+			multiQuery: map[string][]string{"flag": {""}, "q": {"a b"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, reqErr := http.NewRequest("GET", test.url, bytes.NewReader(nil))
+			assert.Nil(t, reqErr)
+
+			ri, prepErr := newRequest(req).prepareRequest(
+				&config.Config{},
+				&config.Event{Source: config.APISource},
+				nil,
+			)
+			assert.Nil(t, prepErr)
+
+			raw := map[string]json.RawMessage{}
+			assert.Nil(t, json.Unmarshal(ri.Payload, &raw))
+			if test.query == nil {
+				assert.Equal(t, "null", string(raw["queryStringParameters"]))
+				assert.Equal(
+					t,
+					"null",
+					string(raw["multiValueQueryStringParameters"]),
+				)
+				return
+			}
+
+			gwR := &events.APIGatewayProxyRequest{}
+			assert.Nil(t, json.Unmarshal(ri.Payload, gwR))
+			assert.Equal(t, test.query, gwR.QueryStringParameters)
+			assert.Equal(t, test.multiQuery, gwR.MultiValueQueryStringParameters)
+		})
+	}
+}
+
+func TestPrepareRequestBinary(t *testing.T) {
+	t.Parallel()
+
+	body := []byte{0x89, 0x50, 0x4e, 0x47, 0x00, 0xff}
+	conf := &config.Config{
+		API: config.API{BinaryMediaTypes: []string{"image/*"}},
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		encoded     bool
+	}{
+		{"binary type", "image/png", "iVBORwD/", true},
+		{"text type", "text/plain", string(body), false},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, reqErr := http.NewRequest(
+				"POST",
+				"https://testing.com/upload",
+				bytes.NewReader(body),
+			)
+			assert.Nil(t, reqErr)
+			req.Header.Set("Content-Type", test.contentType)
+
+			ri, prepErr := newRequest(req).prepareRequest(
+				conf,
+				&config.Event{Source: config.APISource},
+				nil,
+			)
+			assert.Nil(t, prepErr)
+
+			gwR := &events.APIGatewayProxyRequest{}
+			assert.Nil(t, json.Unmarshal(ri.Payload, gwR))
+			assert.Equal(t, test.encoded, gwR.IsBase64Encoded)
+			if test.encoded {
+				assert.Equal(t, test.body, gwR.Body)
+			}
+		})
+	}
+}
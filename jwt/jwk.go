@@ -0,0 +1,204 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a JSON Web Key holding an RSA or EC public key
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// N and E are the modulus and exponent of RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Crv, X and Y are the curve and point of EC keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ParseJWKS parses a JSON Web Key Set. Keys that can't verify tokens, like
+// symmetric or encryption keys, are left out, as identity providers often
+// publish them alongside their signing keys. It's an error for no usable key
+// to be left.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	parsed := &JWKS{}
+	if unmarshalErr := json.Unmarshal(data, parsed); unmarshalErr != nil {
+		return nil, fmt.Errorf("Invalid JWKS: %s", unmarshalErr)
+	}
+
+	jwks := &JWKS{Keys: []JWK{}}
+	var firstErr error
+	for _, key := range parsed.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		if _, keyErr := key.PublicKey(); keyErr != nil {
+			if firstErr == nil {
+				firstErr = keyErr
+			}
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, key)
+	}
+
+	if len(jwks.Keys) == 0 && firstErr != nil {
+		return nil, fmt.Errorf("Invalid JWKS: no usable keys (%s)", firstErr)
+	} else if len(jwks.Keys) == 0 {
+		return nil, errors.New("Invalid JWKS: no usable keys")
+	}
+
+	return jwks, nil
+}
+
+// NewJWK returns the JWK for an RSA or EC public key. When kid is empty the
+// key's thumbprint is used.
+func NewJWK(pub crypto.PublicKey, kid string) (JWK, error) {
+	var key JWK
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		key = JWK{
+			Kty: "RSA",
+			Alg: "RS256",
+			N:   encodeSegment(pub.N.Bytes()),
+			E:   encodeSegment(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		alg, ok := ecAlgorithms[pub.Curve.Params().Name]
+		if !ok {
+			return key, errors.New("Unsupported EC curve")
+		}
+
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		key = JWK{
+			Kty: "EC",
+			Alg: alg,
+			Crv: pub.Curve.Params().Name,
+			X:   encodeSegment(padBytes(pub.X.Bytes(), size)),
+			Y:   encodeSegment(padBytes(pub.Y.Bytes(), size)),
+		}
+	default:
+		return key, errors.New("Unsupported key type")
+	}
+
+	key.Use = "sig"
+	key.Kid = kid
+	if key.Kid == "" {
+		key.Kid = key.thumbprint()
+	}
+
+	return key, nil
+}
+
+// PublicKey returns the public key the JWK holds
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, nErr := decodeSegment(k.N)
+		e, eErr := decodeSegment(k.E)
+		if nErr != nil || eErr != nil || len(n) == 0 || len(e) == 0 {
+			return nil, fmt.Errorf("Invalid RSA key %s", k.Kid)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported EC curve %s for key %s", k.Crv, k.Kid)
+		}
+
+		x, xErr := decodeSegment(k.X)
+		y, yErr := decodeSegment(k.Y)
+		if xErr != nil || yErr != nil {
+			return nil, fmt.Errorf("Invalid EC key %s", k.Kid)
+		}
+
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("Invalid EC key %s", k.Kid)
+		}
+
+		return pub, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported key type %s for key %s", k.Kty, k.Kid)
+}
+
+// thumbprint returns the RFC 7638 thumbprint of the key
+func (k JWK) thumbprint() string {
+	var data string
+	if k.Kty == "RSA" {
+		data = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, k.E, k.N)
+	} else {
+		data = fmt.Sprintf(
+			`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`,
+			k.Crv,
+			k.X,
+			k.Y,
+		)
+	}
+
+	sum := sha256.Sum256([]byte(data))
+	return encodeSegment(sum[:])
+}
+
+// curves are the EC curves keys can use
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// ecAlgorithms are the signing algorithms for each EC curve
+var ecAlgorithms = map[string]string{
+	"P-256": "ES256",
+	"P-384": "ES384",
+	"P-521": "ES512",
+}
+
+// padBytes left pads b with zeros to size bytes
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// encodeSegment base64url encodes data without padding
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSegment decodes base64url data without padding
+func decodeSegment(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(data)
+}
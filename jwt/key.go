@@ -0,0 +1,72 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+// keyBits is the size of generated RSA keys
+const keyBits = 2048
+
+// LoadKey loads a PEM encoded RSA or EC private key
+func LoadKey(path string) (crypto.Signer, error) {
+	data, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("No PEM data found in key file")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, parseErr := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("Unsupported private key type")
+		}
+
+		return signer, nil
+	}
+
+	return nil, errors.New("Unsupported private key type " + block.Type)
+}
+
+// LoadOrCreateKey loads the private key at path, generating and saving an
+// RSA key there if it doesn't exist yet
+func LoadOrCreateKey(path string) (crypto.Signer, error) {
+	if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		return LoadKey(path)
+	}
+
+	key, genErr := rsa.GenerateKey(rand.Reader, keyBits)
+	if genErr != nil {
+		return nil, genErr
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	if writeErr := ioutil.WriteFile(path, data, 0600); writeErr != nil {
+		return nil, writeErr
+	}
+
+	return key, nil
+}
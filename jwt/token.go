@@ -0,0 +1,218 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers SHA-256 for signing
+	_ "crypto/sha512" // registers SHA-384 and SHA-512 for signing
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// The errors returned when a token can't be verified
+var (
+	ErrMalformed        = errors.New("the token is malformed")
+	ErrUnknownKey       = errors.New("the token is signed with an unknown key")
+	ErrInvalidSignature = errors.New("the token signature is invalid")
+	ErrExpired          = errors.New("the token has expired")
+	ErrMissingExpiry    = errors.New("the token has no expiration")
+	ErrWrongAlgorithm   = errors.New("the token algorithm doesn't match its key")
+	ErrNotYetValid      = errors.New("the token is not valid yet")
+)
+
+// header is the header of a token
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// hashes are the hashes used by each signing algorithm
+var hashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// Sign signs the claims with an RSA or EC private key, returning the
+// token. RSA keys sign with RS256 and EC keys with the algorithm for their
+// curve.
+func Sign(claims map[string]interface{}, key crypto.Signer, kid string) (string, error) {
+	jwk, jwkErr := NewJWK(key.Public(), kid)
+	if jwkErr != nil {
+		return "", jwkErr
+	}
+
+	headerData, headerErr := json.Marshal(header{Alg: jwk.Alg, Typ: "JWT", Kid: jwk.Kid})
+	if headerErr != nil {
+		return "", headerErr
+	}
+
+	claimsData, claimsErr := json.Marshal(claims)
+	if claimsErr != nil {
+		return "", claimsErr
+	}
+
+	signingInput := encodeSegment(headerData) + "." + encodeSegment(claimsData)
+
+	hash := hashes[jwk.Alg]
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var signErr error
+		sig, signErr = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+		if signErr != nil {
+			return "", signErr
+		}
+	case *ecdsa.PrivateKey:
+		r, s, signErr := ecdsa.Sign(rand.Reader, key, digest)
+		if signErr != nil {
+			return "", signErr
+		}
+
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig = append(padBytes(r.Bytes(), size), padBytes(s.Bytes(), size)...)
+	default:
+		return "", errors.New("Unsupported key type")
+	}
+
+	return signingInput + "." + encodeSegment(sig), nil
+}
+
+// Verify verifies a token's signature against the keys and checks that it
+// has an expiration, has not expired and is valid at the given time,
+// returning its claims
+func Verify(token string, keys *JWKS, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	headerData, headerErr := decodeSegment(parts[0])
+	claimsData, claimsErr := decodeSegment(parts[1])
+	sig, sigErr := decodeSegment(parts[2])
+	if headerErr != nil || claimsErr != nil || sigErr != nil {
+		return nil, ErrMalformed
+	}
+
+	h := header{}
+	if json.Unmarshal(headerData, &h) != nil {
+		return nil, ErrMalformed
+	}
+
+	claims := make(map[string]interface{})
+	decoder := json.NewDecoder(strings.NewReader(string(claimsData)))
+	decoder.UseNumber()
+	if decoder.Decode(&claims) != nil {
+		return nil, ErrMalformed
+	}
+
+	hash, ok := hashes[h.Alg]
+	if !ok {
+		return nil, fmt.Errorf("the token algorithm %s is not supported", h.Alg)
+	}
+
+	key, keyErr := findKey(keys, h.Kid)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+
+	if key.Alg != "" && key.Alg != h.Alg {
+		return nil, ErrWrongAlgorithm
+	}
+
+	pub, pubErr := key.PublicKey()
+	if pubErr != nil {
+		return nil, pubErr
+	}
+
+	digest := hash.New()
+	digest.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(pub, h.Alg, digest.Sum(nil), sig) {
+		return nil, ErrInvalidSignature
+	}
+
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return nil, ErrMissingExpiry
+	} else if !now.Before(time.Unix(exp, 0)) {
+		return nil, ErrExpired
+	}
+
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Before(time.Unix(nbf, 0)) {
+		return nil, ErrNotYetValid
+	}
+
+	return claims, nil
+}
+
+// findKey finds the key with the given id. Tokens without a key id can only
+// be verified against a set holding a single key.
+func findKey(keys *JWKS, kid string) (JWK, error) {
+	if kid == "" && len(keys.Keys) == 1 {
+		return keys.Keys[0], nil
+	}
+
+	for _, key := range keys.Keys {
+		if key.Kid == kid {
+			return key, nil
+		}
+	}
+
+	return JWK{}, ErrUnknownKey
+}
+
+// verifySignature verifies the signature of a digest
+func verifySignature(pub crypto.PublicKey, alg string, digest, sig []byte) bool {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return false
+		}
+
+		return rsa.VerifyPKCS1v15(pub, hashes[alg], digest, sig) == nil
+	case *ecdsa.PublicKey:
+		if alg != ecAlgorithms[pub.Curve.Params().Name] {
+			return false
+		}
+
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	}
+
+	return false
+}
+
+// numericClaim returns the value of a numeric date claim
+func numericClaim(claims map[string]interface{}, name string) (int64, bool) {
+	num, ok := claims[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+
+	value, floatErr := num.Float64()
+	if floatErr != nil {
+		return 0, false
+	}
+
+	return int64(value), true
+}
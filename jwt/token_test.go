@@ -0,0 +1,185 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, rsaErr := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, rsaErr)
+	ecKey, ecErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, ecErr)
+	otherKey, otherErr := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, otherErr)
+
+	keys := &JWKS{}
+	for _, pub := range []crypto.PublicKey{rsaKey.Public(), ecKey.Public()} {
+		jwk, jwkErr := NewJWK(pub, "")
+		assert.Nil(t, jwkErr)
+		keys.Keys = append(keys.Keys, jwk)
+	}
+
+	now := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		name    string
+		key     crypto.Signer
+		kid     string
+		claims  map[string]interface{}
+		tamper  bool
+		wantErr error
+	}{
+		{
+			name:   "verifies an RSA token",
+			key:    rsaKey,
+			claims: map[string]interface{}{"sub": "user-1", "exp": 1500000060},
+		},
+		{
+			name:   "verifies an EC token",
+			key:    ecKey,
+			claims: map[string]interface{}{"sub": "user-1", "exp": 1500000060},
+		},
+		{
+			name:    "rejects an expired token",
+			key:     rsaKey,
+			claims:  map[string]interface{}{"exp": 1500000000},
+			wantErr: ErrExpired,
+		},
+		{
+			name:    "rejects a token that isn't valid yet",
+			key:     rsaKey,
+			claims:  map[string]interface{}{"nbf": 1500000060, "exp": 1500000120},
+			wantErr: ErrNotYetValid,
+		},
+		{
+			name:    "rejects a token without an expiration",
+			key:     rsaKey,
+			claims:  map[string]interface{}{"sub": "user-1"},
+			wantErr: ErrMissingExpiry,
+		},
+		{
+			name:    "rejects a tampered token",
+			key:     rsaKey,
+			claims:  map[string]interface{}{"sub": "user-1"},
+			tamper:  true,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "rejects an unknown key",
+			key:     otherKey,
+			claims:  map[string]interface{}{"sub": "user-1"},
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "rejects a known kid signed by another key",
+			key:     otherKey,
+			kid:     keys.Keys[0].Kid,
+			claims:  map[string]interface{}{"sub": "user-1"},
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			token, signErr := Sign(test.claims, test.key, test.kid)
+			assert.Nil(t, signErr)
+
+			if test.tamper {
+				parts := strings.Split(token, ".")
+				parts[1] = encodeSegment([]byte(`{"sub":"admin"}`))
+				token = strings.Join(parts, ".")
+			}
+
+			claims, verifyErr := Verify(token, keys, now)
+			assert.Equal(t, test.wantErr, verifyErr)
+			if test.wantErr == nil {
+				assert.Equal(t, "user-1", claims["sub"])
+			}
+		})
+	}
+
+	_, malformedErr := Verify("not-a-token", keys, now)
+	assert.Equal(t, ErrMalformed, malformedErr)
+
+	// a key that declares its algorithm only verifies tokens signed with it
+	token, signErr := Sign(
+		map[string]interface{}{"sub": "user-1", "exp": 1500000060},
+		rsaKey,
+		"",
+	)
+	assert.Nil(t, signErr)
+
+	rs512 := keys.Keys[0]
+	rs512.Alg = "RS512"
+	_, algErr := Verify(token, &JWKS{Keys: []JWK{rs512}}, now)
+	assert.Equal(t, ErrWrongAlgorithm, algErr)
+}
+
+func TestParseJWKS(t *testing.T) {
+	t.Parallel()
+
+	key, keyErr := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, keyErr)
+
+	jwk, jwkErr := NewJWK(key.Public(), "test-key")
+	assert.Nil(t, jwkErr)
+
+	data, marshalErr := json.Marshal(JWKS{Keys: []JWK{jwk}})
+	assert.Nil(t, marshalErr)
+
+	jwks, parseErr := ParseJWKS(data)
+	assert.Nil(t, parseErr)
+	assert.Equal(t, "test-key", jwks.Keys[0].Kid)
+
+	pub, pubErr := jwks.Keys[0].PublicKey()
+	assert.Nil(t, pubErr)
+	assert.Equal(t, key.Public(), pub)
+
+	_, invalidErr := ParseJWKS([]byte(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`))
+	assert.NotNil(t, invalidErr)
+
+	// keys that can't verify tokens are skipped
+	mixed, mixedErr := ParseJWKS([]byte(`{"keys":[
+		{"kty":"oct","k":"c2VjcmV0"},
+		{"kty":"RSA","use":"enc","kid":"enc-key","n":"` + jwk.N + `","e":"` + jwk.E + `"},
+		{"kty":"RSA","use":"sig","kid":"test-key","n":"` + jwk.N + `","e":"` + jwk.E + `"}
+	]}`))
+	assert.Nil(t, mixedErr)
+	assert.Len(t, mixed.Keys, 1)
+	assert.Equal(t, "test-key", mixed.Keys[0].Kid)
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	t.Parallel()
+
+	dir, dirErr := ioutil.TempDir("", "ladle-jwt")
+	assert.Nil(t, dirErr)
+	defer os.RemoveAll(dir)
+
+	keyPath := path.Join(dir, "token_key.pem")
+
+	created, createErr := LoadOrCreateKey(keyPath)
+	assert.Nil(t, createErr)
+
+	loaded, loadErr := LoadOrCreateKey(keyPath)
+	assert.Nil(t, loadErr)
+	assert.Equal(t, created.Public(), loaded.Public())
+}
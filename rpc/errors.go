@@ -0,0 +1,18 @@
+package rpc
+
+import "fmt"
+
+// ThrottleError is returned by an Invoker when a function is already running
+// as many instances as its reserved concurrency allows
+type ThrottleError struct {
+	// Name is the name of the throttled function
+	Name string
+}
+
+// Error returns the error message, matching Lambda's
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf(
+		"TooManyRequestsException: Rate Exceeded for function %s",
+		e.Name,
+	)
+}
@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"log"
 	"net"
 	"net/rpc"
@@ -14,15 +15,33 @@ func Register(name string) {
 }
 
 // Listen listens with rpc to the given port and passes messages on
-// to the called function
-func Listen(conf *config.Config, i Invoker) {
+// to the called function. It stops listening when ctx is done.
+func Listen(ctx context.Context, conf *config.Config, i Invoker) error {
 	globalInvoker = i
 
 	lis, lisErr := net.Listen("tcp", conf.RPCAddress)
 	if lisErr != nil {
-		panic(lisErr)
+		return lisErr
 	}
 
+	go func() {
+		<-ctx.Done()
+		log.Printf("RPC: Shutting down\n")
+		lis.Close()
+	}()
+
 	log.Printf("RPC: Listening on %s\n", conf.RPCAddress)
-	rpc.Accept(lis)
+	for {
+		conn, acceptErr := lis.Accept()
+		if acceptErr != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return acceptErr
+			}
+		}
+
+		go rpc.ServeConn(conn)
+	}
 }